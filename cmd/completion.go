@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/aws/endpoint"
+	"github.com/stknohg/ec2rdp/internal/cache"
+)
+
+const (
+	completionCacheTTL = 60 * time.Second
+	completionTimeout  = 5 * time.Second
+	regionCacheTTL     = 24 * time.Hour
+)
+
+func invokeRegionCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// use the region of the profile to choose the partition
+	cfg, err := loadCompletionConfig("")
+	regions := ec2.EmbeddedRegions("")
+	if err == nil && cfg.Region != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+		defer cancel()
+		regions = newRegionProvider(ec2.NewAPI(cfg), nil).Regions(ctx, cfg.Region)
+	}
+	values := []string{}
+	for _, r := range regions {
+		if r.OptInStatus == ec2.NotOptedIn {
+			values = append(values, fmt.Sprintf("%v\tnot opted in", r.Name))
+		} else {
+			values = append(values, r.Name)
+		}
+	}
+	return values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// newRegionProvider returns the region provider cached per account, which is chosen by the profile and the roles.
+func newRegionProvider(ec2api ec2.EC2API, logf func(format string, a ...any)) *ec2.RegionProvider {
+	c, _ := cache.New("regions", regionCacheTTL)
+	return &ec2.RegionProvider{API: ec2api, Cache: c, CacheKey: fmt.Sprintf("%v|%v", cpProfileName, strings.Join(cpRoleArns, ",")), Logf: logf}
+}
+
+func invokeInstanceCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeWithCache("instances", func(ec2api ec2.EC2API, ctx context.Context) ([]string, error) {
+		instances, err := ec2.FindInstances(ec2api, ctx, []types.Filter{ec2.WindowsFilter()})
+		if err != nil {
+			return nil, err
+		}
+		values := []string{}
+		for _, i := range instances {
+			values = append(values, fmt.Sprintf("%v\t%v", i.InstanceId, describeCompletion(i.Name, string(i.State))))
+		}
+		return values, nil
+	})
+}
+
+func invokeEndpointCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeWithCache("endpoints", func(ec2api ec2.EC2API, ctx context.Context) ([]string, error) {
+		endpoints, err := ec2.ListEICEndpoints(ec2api, ctx)
+		if err != nil {
+			return nil, err
+		}
+		values := []string{}
+		for _, e := range endpoints {
+			values = append(values, fmt.Sprintf("%v\t%v", e.EndpointId, describeCompletion(e.Name, e.VpcId)))
+		}
+		return values, nil
+	})
+}
+
+func invokeProfileCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return aws.ListProfiles(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeWithCache returns the completion values fetched with the profile and region flags.
+// The values are cached on disk for a short time to keep tab completion instant.
+func completeWithCache(kind string, fetch func(ec2api ec2.EC2API, ctx context.Context) ([]string, error)) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadCompletionConfig(cpRegionName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	key := fmt.Sprintf("%v|%v|%v|%v", kind, cpProfileName, cfg.Region, strings.Join(cpRoleArns, ","))
+	c, err := cache.New("completion", completionCacheTTL)
+	values := []string{}
+	if err == nil && c.Get(key, &values) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	values, err = fetch(ec2.NewAPI(cfg), ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	if c != nil {
+		c.Set(key, values)
+	}
+	return values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// loadCompletionConfig returns the AWS config with the profile, endpoint and role flags without prompting.
+func loadCompletionConfig(region string) (awssdk.Config, error) {
+	// MFA code can not be prompted while completing, so the cached credentials are required with MFA
+	cfg, err := aws.LoadConfig(cpProfileName, region, aws.WithMFATokenProvider(aws.NoMFAToken))
+	if err != nil {
+		return cfg, err
+	}
+	flags, _ := endpoint.ParseFlags(cpEndpointURLs)
+	cfg = endpoint.With(cfg, flags)
+	if len(cpRoleArns) > 0 {
+		options := getAssumeRoleOptions()
+		options.TokenProvider = aws.NoMFAToken
+		cfg = aws.AssumeRole(cfg, options)
+	}
+	return cfg, nil
+}
+
+func describeCompletion(name string, detail string) string {
+	if name == "" {
+		return detail
+	}
+	return fmt.Sprintf("%v (%v)", name, detail)
+}
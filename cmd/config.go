@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/aws/endpoint"
+	"github.com/stknohg/ec2rdp/internal/aws/ssm"
+	"github.com/stknohg/ec2rdp/internal/aws/sts"
+)
+
+// Endpoint parameters
+var cpEndpointURLs []string
+
+func addEndpointURLFlags(c *cobra.Command) {
+	c.Flags().StringArrayVar(&cpEndpointURLs, "endpoint-url", []string{}, fmt.Sprintf("Custom endpoint [SERVICE=]URL. SERVICE is one of %v (default: all API services). Can be specified multiple times", strings.Join(endpoint.ServiceNames(), ", ")))
+}
+
+func validateEndpointURLFlags() error {
+	_, err := endpoint.ParseFlags(cpEndpointURLs)
+	return err
+}
+
+// getAWSConfig returns the AWS config of --profile and --region flags, and assumes the roles of --role-arn flags on it.
+// The credentials are verified before calling other APIs, and logf prints the account, role and region to use.
+func getAWSConfig(logf func(format string, a ...any)) (awssdk.Config, error) {
+	cfg, err := aws.GetConfig(cpProfileName, cpRegionName)
+	if err != nil {
+		return cfg, err
+	}
+	// the API call in the invalid region fails with the DNS error, so validate it first
+	err = ec2.ValidateRegionName(cfg.Region)
+	if err != nil {
+		return cfg, err
+	}
+	flags, _ := endpoint.ParseFlags(cpEndpointURLs)
+	cfg = endpoint.With(cfg, flags)
+	if len(cpRoleArns) > 0 {
+		cfg = aws.AssumeRole(cfg, getAssumeRoleOptions())
+	}
+	// MFA token may be prompted while retrieving the credentials, so the context has no timeout
+	err = preflight(sts.NewAPI(cfg), context.Background(), cfg.Region, logf)
+	return cfg, err
+}
+
+// withStreamURL sets the custom ssmmessages endpoint URL to the SSM session.
+func withStreamURL(cfg awssdk.Config, s *ssm.PortForwardingSession) *ssm.PortForwardingSession {
+	s.StreamURL, _ = endpoint.ResolveService(cfg, endpoint.SSMMessages)
+	return s
+}
+
+// preflight calls sts:GetCallerIdentity to verify the credentials.
+func preflight(stsapi sts.STSAPI, ctx context.Context, region string, logf func(format string, a ...any)) error {
+	identity, err := sts.GetCallerIdentity(stsapi, ctx)
+	if err != nil {
+		return aws.DescribeError(cpProfileName, err)
+	}
+	logf("Use AWS account %v, role %v, region %v\n", identity.Account, identity.Role(), region)
+	return nil
+}
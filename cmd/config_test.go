@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awssts "github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/stknohg/ec2rdp/internal/aws"
+	"github.com/stknohg/ec2rdp/internal/aws/ssm"
+)
+
+type MockSTSAPI struct {
+	GetCallerIdentityOutput *awssts.GetCallerIdentityOutput
+	Error                   error
+}
+
+func (m *MockSTSAPI) GetCallerIdentity(ctx context.Context, params *awssts.GetCallerIdentityInput, optFns ...func(*awssts.Options)) (*awssts.GetCallerIdentityOutput, error) {
+	return m.GetCallerIdentityOutput, m.Error
+}
+
+func Test_preflight(t *testing.T) {
+	out := &strings.Builder{}
+	logf := func(format string, a ...any) { fmt.Fprintf(out, format, a...) }
+	api := &MockSTSAPI{GetCallerIdentityOutput: &awssts.GetCallerIdentityOutput{
+		Account: awssdk.String("123456789012"),
+		Arn:     awssdk.String("arn:aws:sts::123456789012:assumed-role/Admin/ec2rdp-1700000000"),
+	}}
+	err := preflight(api, context.Background(), "ap-northeast-1", logf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "123456789012") || !strings.Contains(got, "Admin") || !strings.Contains(got, "ap-northeast-1") {
+		t.Errorf("Invalid output %v", got)
+	}
+
+	api.Error = &smithy.GenericAPIError{Code: "ExpiredToken"}
+	err = preflight(api, context.Background(), "ap-northeast-1", logf)
+	if !errors.Is(err, aws.ErrExpiredCredentials) {
+		t.Errorf("Expired credentials must be described (%v)", err)
+	}
+}
+
+func Test_getAWSConfig_InvalidRegion(t *testing.T) {
+	defer func(region string) { cpRegionName = region }(cpRegionName)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+
+	// the typo of the region is reported before verifying the credentials
+	cpRegionName = "us-eats1"
+	_, err := getAWSConfig(func(format string, a ...any) {
+		t.Errorf("Credentials must not be verified in the invalid region")
+	})
+	if err == nil || !strings.Contains(err.Error(), "region us-eats1 is invalid") {
+		t.Errorf("Invalid region must be reported (%v)", err)
+	}
+}
+
+func Test_withStreamURL(t *testing.T) {
+	t.Setenv("AWS_ENDPOINT_URL_SSMMESSAGES", "https://vpce-1.ssmmessages.ap-northeast-1.vpce.amazonaws.com")
+	s := withStreamURL(awssdk.Config{}, ssm.NewSSMSessionPortForward(&MockSSMAPI{}, "i-01234567890abcdef", 3389, 13389, "test"))
+	if s.StreamURL != "https://vpce-1.ssmmessages.ap-northeast-1.vpce.amazonaws.com" {
+		t.Errorf("Custom ssmmessages endpoint is not used (%v)", s.StreamURL)
+	}
+}
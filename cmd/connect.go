@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+)
+
+// Connection modes
+const (
+	modePublic = "public"
+	modeSSM    = "ssm"
+	modeEICE   = "eice"
+)
+
+// environment variable to change the default preference order
+const preferEnvName = "EC2RDP_PREFER"
+
+var connectModes = []string{modeSSM, modeEICE, modePublic}
+
+var connectPreference []string
+
+// connectCmd represents the connect command
+var connectCmd = &cobra.Command{
+	Use:   "connect",
+	Short: "Connect to EC2 instance with the automatically selected mode",
+	Long: `Connect to EC2 instance with the automatically selected mode.
+The first available mode in the preference order (--prefer flag or EC2RDP_PREFER environment variable) is used.
+public  : the instance has public IP address or DNS name and RDP port is open
+ssm     : SSM Agent is online
+eice    : EC2 Instance Connect Endpoint exists in the VPC`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := validateInstanceFlags(); err != nil {
+			return err
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		if err := validateEndpointURLFlags(); err != nil {
+			return err
+		}
+		if err := validateStartFlags(); err != nil {
+			return err
+		}
+		if _, err := parsePreference(connectPreference); err != nil {
+			return err
+		}
+		if cpPemFile == "" && !cpUserPassword {
+			return errors.New("--pemfile or --password flag is requied")
+		}
+		if cpPemFile != "" {
+			err := validatePemFile(cpPemFile)
+			if err != nil {
+				return err
+			}
+		}
+		err := validatePort(cpPort)
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: invokeConnectCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(connectCmd)
+	addConnectFlags(connectCmd)
+	addClientFlags(connectCmd)
+	addDisplayFlags(connectCmd)
+}
+
+func addConnectFlags(c *cobra.Command) {
+	addInstanceFlags(c)
+	addStartFlags(c)
+	addWaitPasswordFlags(c)
+	c.Flags().StringVarP(&cpPemFile, "pemfile", "p", "", ".pem file path")
+	c.Flags().IntVar(&cpPort, "port", 3389, "RDP port no")
+	c.Flags().StringVar(&cpUserName, "user", "Administrator", "RDP username")
+	c.Flags().BoolVarP(&cpUserPassword, "password", "P", false, "RDP passowrd")
+	c.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
+	c.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addAssumeRoleFlags(c)
+	addEndpointURLFlags(c)
+	c.Flags().IntVar(&cpReconnectAttempts, "reconnect", 5, "Max reconnect attempts when the tunnel is disconnected (0 to disable)")
+	c.Flags().StringVarP(&eiceEndpointId, "endpointid", "e", "", "EC2 Instance Connect Endpoint ID")
+	c.Flags().StringSliceVar(&connectPreference, "prefer", []string{}, fmt.Sprintf("Preference order of connection modes (default: %v environment variable or %v)", preferEnvName, strings.Join(connectModes, ",")))
+	//
+	c.MarkFlagFilename("pemfile", "pem")
+	c.MarkFlagsMutuallyExclusive("pemfile", "password")
+	// custom completion
+	c.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("profile", invokeProfileCompletion)
+	c.RegisterFlagCompletionFunc("endpointid", invokeEndpointCompletion)
+	c.RegisterFlagCompletionFunc("prefer", cobra.FixedCompletions(connectModes, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// parsePreference returns the preference order from the flag, the environment variable or the default.
+func parsePreference(values []string) ([]string, error) {
+	if len(values) == 0 {
+		if v := os.Getenv(preferEnvName); v != "" {
+			values = strings.Split(v, ",")
+		} else {
+			values = connectModes
+		}
+	}
+	preference := []string{}
+	for _, v := range values {
+		mode := strings.ToLower(strings.TrimSpace(v))
+		if !slices.Contains(connectModes, mode) {
+			return nil, fmt.Errorf("invalid connection mode %q (set %v)", v, strings.Join(connectModes, ", "))
+		}
+		if slices.Contains(preference, mode) {
+			return nil, fmt.Errorf("connection mode %v is duplicated", mode)
+		}
+		preference = append(preference, mode)
+	}
+	return preference, nil
+}
+
+func invokeConnectCommand(_ *cobra.Command, _ []string) error {
+	preference, _ := parsePreference(connectPreference)
+
+	// start instance if needed, then the selected mode connects to the running instance
+	ctx, p, cleanup, err := prepareInstance(readiness{PasswordData: !cpUserPassword, SSMOnline: slices.Contains(preference, modeSSM)})
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	// check available modes
+	statuses, err := getInstanceStatuses(p.ec2api, p.ssmapi, ctx, []types.Filter{ec2.InstanceIdFilter(p.instanceId)}, printProgress)
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		return fmt.Errorf("instance %v not found", p.instanceId)
+	}
+	mode, err := chooseConnectMode(statuses[0], preference, eiceEndpointId, isPortOpen)
+	if err != nil {
+		return err
+	}
+
+	// run the selected mode
+	switch mode {
+	case modePublic:
+		return runPublicMode(ctx, p)
+	case modeSSM:
+		return runSSMMode(ctx, p)
+	default:
+		return runEICEMode(ctx, p)
+	}
+}
+
+// chooseConnectMode returns the first available mode in the preference order, and explains why the other modes are skipped.
+func chooseConnectMode(status instanceStatus, preference []string, endpointId string, probe func(hostName string, port int) bool) (string, error) {
+	reasons := []string{}
+	for _, mode := range preference {
+		ok, reason := checkConnectMode(status, mode, endpointId, probe)
+		if ok {
+			fmt.Printf("Use %v mode: %v\n", mode, reason)
+			return mode, nil
+		}
+		fmt.Printf("Skip %v mode: %v\n", mode, reason)
+		reasons = append(reasons, fmt.Sprintf("  %v: %v", mode, reason))
+	}
+	return "", fmt.Errorf("no connection mode is available for instance %v\n%v", status.InstanceId, strings.Join(reasons, "\n"))
+}
+
+// checkConnectMode reports whether the mode is available, and the reason.
+func checkConnectMode(status instanceStatus, mode string, endpointId string, probe func(hostName string, port int) bool) (bool, string) {
+	if status.State != types.InstanceStateNameRunning {
+		return false, fmt.Sprintf("instance is %v", status.State)
+	}
+	switch mode {
+	case modePublic:
+		if !status.PublicReachable() {
+			return false, "instance has no public IP address or DNS name"
+		}
+		hostName := status.PublicDnsName
+		if hostName == "" {
+			hostName = status.PublicIpAddress
+		}
+		if !probe(hostName, cpPort) {
+			return false, fmt.Sprintf("port %v of %v is not open", cpPort, hostName)
+		}
+		return true, fmt.Sprintf("port %v of %v is open", cpPort, hostName)
+	case modeSSM:
+		if status.SSMPingStatus == "" {
+			return false, "instance is not managed by SSM"
+		}
+		if !status.SSMReachable() {
+			return false, fmt.Sprintf("SSM Agent is not online (PingStatus %v)", status.SSMPingStatus)
+		}
+		return true, "SSM Agent is online"
+	case modeEICE:
+		if endpointId != "" {
+			return true, fmt.Sprintf("EC2 Instance Connect Endpoint %v is specified", endpointId)
+		}
+		if !status.EICEReachable() {
+			return false, fmt.Sprintf("no EC2 Instance Connect Endpoint in %v", orDash(status.VpcId))
+		}
+		return true, fmt.Sprintf("EC2 Instance Connect Endpoint %v is in %v", status.EICEndpointId, status.VpcId)
+	}
+	return false, "unknown mode"
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+)
+
+func Test_parsePreference(t *testing.T) {
+	t.Setenv(preferEnvName, "")
+	if p, err := parsePreference(nil); err != nil || !slices.Equal(p, connectModes) {
+		t.Errorf("Invalid default preference %v (%v)", p, err)
+	}
+	t.Setenv(preferEnvName, "public, SSM")
+	if p, err := parsePreference(nil); err != nil || !slices.Equal(p, []string{"public", "ssm"}) {
+		t.Errorf("Invalid preference from environment variable %v (%v)", p, err)
+	}
+	if p, err := parsePreference([]string{"eice"}); err != nil || !slices.Equal(p, []string{"eice"}) {
+		t.Errorf("Flag must be preferred to environment variable %v (%v)", p, err)
+	}
+	for _, v := range [][]string{{"rdp"}, {"ssm", "ssm"}} {
+		if _, err := parsePreference(v); err == nil {
+			t.Errorf("Failed to detect invalid preference %v", v)
+		}
+	}
+}
+
+func Test_chooseConnectMode(t *testing.T) {
+	defer func(v int) { cpPort = v }(cpPort)
+	cpPort = 3389
+	running := ec2.InstanceSummary{InstanceId: "i-01234567890abcdef", State: ec2types.InstanceStateNameRunning, VpcId: "vpc-12345678"}
+	portOpen := func(hostName string, port int) bool { return true }
+	portClosed := func(hostName string, port int) bool { return false }
+	all := []string{modeSSM, modeEICE, modePublic}
+
+	tests := []struct {
+		name       string
+		status     instanceStatus
+		preference []string
+		endpointId string
+		probe      func(string, int) bool
+		expected   string
+	}{
+		{"ssm online", instanceStatus{InstanceSummary: running, SSMPingStatus: "Online", EICEndpointId: "eice-1234567890"}, all, "", portOpen, modeSSM},
+		{"ssm connection lost", instanceStatus{InstanceSummary: running, SSMPingStatus: "ConnectionLost", EICEndpointId: "eice-1234567890"}, all, "", portOpen, modeEICE},
+		{"eice specified", instanceStatus{InstanceSummary: running}, all, "eice-1234567890", portOpen, modeEICE},
+		{"public preferred", instanceStatus{InstanceSummary: ec2.InstanceSummary{InstanceId: "i-01234567890abcdef", State: ec2types.InstanceStateNameRunning, PublicIpAddress: "203.0.113.1"}, SSMPingStatus: "Online"}, []string{modePublic, modeSSM}, "", portOpen, modePublic},
+		{"public port closed", instanceStatus{InstanceSummary: ec2.InstanceSummary{InstanceId: "i-01234567890abcdef", State: ec2types.InstanceStateNameRunning, PublicIpAddress: "203.0.113.1"}, SSMPingStatus: "Online"}, []string{modePublic, modeSSM}, "", portClosed, modeSSM},
+		{"nothing", instanceStatus{InstanceSummary: running}, all, "", portOpen, ""},
+		{"stopped", instanceStatus{InstanceSummary: ec2.InstanceSummary{InstanceId: "i-01234567890abcdef", State: ec2types.InstanceStateNameStopped}, EICEndpointId: "eice-1234567890"}, all, "", portOpen, ""},
+	}
+	for _, tt := range tests {
+		mode, err := chooseConnectMode(tt.status, tt.preference, tt.endpointId, tt.probe)
+		if mode != tt.expected {
+			t.Errorf("%v: mode must be %q (%q, %v)", tt.name, tt.expected, mode, err)
+		}
+		if tt.expected == "" && (err == nil || !strings.Contains(err.Error(), "ssm:")) {
+			t.Errorf("%v: error must explain skipped modes (%v)", tt.name, err)
+		}
+	}
+}
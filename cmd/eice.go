@@ -4,17 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
-	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/hashicorp/go-version"
 	"github.com/spf13/cobra"
-	"github.com/stknohg/ec2rdp/internal/aws"
 	"github.com/stknohg/ec2rdp/internal/aws/ec2"
 	"github.com/stknohg/ec2rdp/internal/aws/ec2instanceconnect"
-	"github.com/stknohg/ec2rdp/internal/connector"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
 )
 
 var (
@@ -27,7 +22,16 @@ var eiceCmd = &cobra.Command{
 	Short: "Connect to EC2 instance via EC2 Instance Connect Endpoint",
 	Long:  `Connect to EC2 instance via EC2 Instance Connect Endpoint`,
 	Args: func(cmd *cobra.Command, args []string) error {
-		if installed, err := isAWSCLIInstalled(); !installed {
+		if err := validateInstanceFlags(); err != nil {
+			return err
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		if err := validateEndpointURLFlags(); err != nil {
+			return err
+		}
+		if err := validateStartFlags(); err != nil {
 			return err
 		}
 		if cpPemFile == "" && !cpUserPassword {
@@ -52,66 +56,55 @@ var eiceCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(eiceCmd)
-	eiceCmd.Flags().StringVarP(&cpInstanceId, "instance", "i", "", "EC2 Instance ID")
-	eiceCmd.Flags().StringVarP(&cpPemFile, "pemfile", "p", "", ".pem file path")
-	eiceCmd.Flags().IntVar(&cpPort, "port", 3389, "RDP port no")
-	eiceCmd.Flags().StringVar(&cpUserName, "user", "Administrator", "RDP username")
-	eiceCmd.Flags().BoolVarP(&cpUserPassword, "password", "P", false, "RDP passowrd")
-	eiceCmd.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
-	eiceCmd.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
-	eiceCmd.Flags().StringVarP(&eiceEndpointId, "endpointid", "e", "", "EC2 Instance Connect Endpoint ID")
+	addEICEFlags(eiceCmd)
+	addClientFlags(eiceCmd)
+	addDisplayFlags(eiceCmd)
+}
+
+func addEICEFlags(c *cobra.Command) {
+	addInstanceFlags(c)
+	addStartFlags(c)
+	addWaitPasswordFlags(c)
+	c.Flags().StringVarP(&cpPemFile, "pemfile", "p", "", ".pem file path")
+	c.Flags().IntVar(&cpPort, "port", 3389, "RDP port no")
+	c.Flags().StringVar(&cpUserName, "user", "Administrator", "RDP username")
+	c.Flags().BoolVarP(&cpUserPassword, "password", "P", false, "RDP passowrd")
+	c.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
+	c.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addAssumeRoleFlags(c)
+	addEndpointURLFlags(c)
+	c.Flags().IntVar(&cpReconnectAttempts, "reconnect", 5, "Max reconnect attempts when the tunnel is disconnected (0 to disable)")
+	c.Flags().StringVarP(&eiceEndpointId, "endpointid", "e", "", "EC2 Instance Connect Endpoint ID")
 	//
-	eiceCmd.MarkFlagRequired("instance")
-	eiceCmd.MarkFlagFilename("pemfile", "pem")
-	eiceCmd.MarkFlagsMutuallyExclusive("pemfile", "password")
+	c.MarkFlagFilename("pemfile", "pem")
+	c.MarkFlagsMutuallyExclusive("pemfile", "password")
 	// custom completion
-	eiceCmd.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("profile", invokeProfileCompletion)
+	c.RegisterFlagCompletionFunc("endpointid", invokeEndpointCompletion)
 }
 
 func invokeEICECommand(_ *cobra.Command, _ []string) error {
-	// check if connector application installed
-	connector := connector.DefaultConnector{}
-	_, err := connector.IsInstalled()
-	if err != nil {
-		return err
-	}
-
-	// get aws config
-	cfg := aws.GetConfig(cpProfileName, cpRegionName)
-	ec2api := ec2.NewAPI(cfg)
-	ctx := context.Background()
-
-	// check instance exists
-	_, err = ec2.IsInstanceExist(ec2api, ctx, cpInstanceId)
+	ctx, p, cleanup, err := prepareInstance(readiness{PasswordData: !cpUserPassword})
+	defer cleanup()
 	if err != nil {
 		return err
 	}
+	return runEICEMode(ctx, p)
+}
 
-	// get instance metadata information
-	metadata, err := ec2.GetInstanceMetadataForEICE(ec2api, ctx, cpInstanceId)
+// runEICEMode connects to the prepared instance through EC2 Instance Connect Endpoint.
+func runEICEMode(ctx context.Context, p *preparedInstance) error {
+	// get instance metadata and EC2 Insntance Connect Endpoint information
+	metadata, fetchResult, err := getEICETarget(p.ec2api, ctx, p.instanceId, eiceEndpointId)
 	if err != nil {
 		return err
 	}
-	if metadata.State.Name != types.InstanceStateNameRunning {
-		return fmt.Errorf("instance %v is %v (status code=%d)", cpInstanceId, metadata.State.Name, *metadata.State.Code)
-	}
-
-	// get EC2 Insntance Connect Endpoint information
-	var fetchResult *ec2.EICEndpointMetadata
-	if eiceEndpointId != "" {
-		fetchResult, err = ec2.FetchEICEndpointById(ec2api, ctx, eiceEndpointId)
-		if err != nil {
-			return err
-		}
-	} else {
-		fetchResult, err = ec2.FetchEICEndpointByVpc(ec2api, ctx, metadata.VpcId)
-		if err != nil {
-			return err
-		}
+	if eiceEndpointId == "" {
 		fmt.Printf("Find EC2 Instance Connect Endpoint %v in the VPC\n", fetchResult.EndpointId)
 	}
 	// get administrator password
-	password, message, err := getAdministratorPasswordWithPrompt(ec2api, ctx, cpInstanceId, cpPemFile, cpUserPassword)
+	password, message, err := getAdministratorPasswordWithPrompt(p.ec2api, ctx, p.instanceId, cpPemFile, cpUserPassword)
 	if err != nil {
 		return err
 	}
@@ -126,66 +119,45 @@ func invokeEICECommand(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
-	// Open WebSocket tunnel with AWS CLI
-	wspid, err := ec2instanceconnect.OpenTunnel(cfg, ctx, fetchResult.EndpointId, fetchResult.DnsName, metadata.PrivateIpAddress, localPort, cpPort)
+	// Open WebSocket tunnel
+	eiceTunnel := newReconnectingTunnel(func() tunnel.Tunnel {
+		return ec2instanceconnect.NewTunnel(p.cfg, fetchResult.EndpointId, fetchResult.DnsName, metadata.PrivateIpAddress, localPort, cpPort)
+	})
+	err = openTunnel(ctx, eiceTunnel)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Opening WebSocket tunnel (pid=%v)\n", wspid)
-	for i := 1; ; i++ {
-		if isPortOpen(localHostName, localPort) {
-			break
-		}
-		time.Sleep(500 * time.Millisecond)
-		if i >= 10 {
-			return fmt.Errorf("%v port %v is not open", localHostName, localPort)
-		}
-	}
+	fmt.Printf("Opening %v\n", eiceTunnel)
 	fmt.Printf("Start listening %v:%v\n", localHostName, localPort)
 
 	// connect
-	connector.HostName = localHostName
-	connector.Port = localPort
-	connector.UserName = cpUserName
-	connector.PlainPassword = password
-	connector.WaitFor = true // always true
-	return connectEICEInstance(&connector, wspid)
+	p.param.HostName = localHostName
+	p.param.Port = localPort
+	p.param.UserName = cpUserName
+	p.param.PlainPassword = password
+	p.param.WaitFor = true // always true
+	return connectTunnelInstance(ctx, p.con, eiceTunnel)
 }
 
-func isAWSCLIInstalled() (bool, error) {
-	_, err := exec.LookPath("aws")
-	if err != nil {
-		return false, errors.New("AWS CLI is not found")
-	}
-	output, err := exec.Command("aws", "--version").Output()
-	if err != nil {
-		return false, errors.New("failed to get AWS CLI version")
-	}
-	cliVersion, err := version.NewVersion(strings.Split(strings.Split(string(output), " ")[0], "/")[1])
+func getEICETarget(ec2api ec2.EC2API, ctx context.Context, instanceId string, endpointId string) (*ec2.InstanceMetadataForEICE, *ec2.EICEndpointMetadata, error) {
+	// get instance metadata information
+	metadata, err := ec2.GetInstanceMetadataForEICE(ec2api, ctx, instanceId)
 	if err != nil {
-		return false, errors.New("failed to get AWS CLI version")
+		return nil, nil, err
 	}
-	constraint, _ := version.NewConstraint(">=2.12.0")
-	result := constraint.Check(cliVersion)
-	if !result {
-		return false, fmt.Errorf("AWS CLI 2.12.0 later is required (current version=%s)", cliVersion)
+	if metadata.State.Name != types.InstanceStateNameRunning {
+		return nil, nil, fmt.Errorf("instance %v is %v (status code=%d)", instanceId, metadata.State.Name, *metadata.State.Code)
 	}
-	return true, nil
-}
 
-func connectEICEInstance(con connector.Connector, wspid int) error {
-	err := con.PreConnect()
-	if err != nil {
-		return err
+	// get EC2 Insntance Connect Endpoint information
+	var fetchResult *ec2.EICEndpointMetadata
+	if endpointId != "" {
+		fetchResult, err = ec2.FetchEICEndpointById(ec2api, ctx, endpointId)
+	} else {
+		fetchResult, err = ec2.FetchEICEndpointByVpc(ec2api, ctx, metadata.VpcId)
 	}
-	err = con.Connect()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer func() {
-		con.PostConnect()
-		fmt.Printf("Close WebSocket tunnel (pid=%v)\n", wspid)
-		ec2instanceconnect.CloseTunnel(wspid)
-	}()
-	return nil
+	return metadata, fetchResult, nil
 }
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/aws/ssm"
+	"github.com/stknohg/ec2rdp/internal/picker"
+	"golang.org/x/term"
+)
+
+// Instance selector parameters
+var (
+	cpInstanceName    string
+	cpInstanceFilters []string
+	cpChooseInstance  bool
+)
+
+func addInstanceFlags(c *cobra.Command) {
+	c.Flags().StringVarP(&cpInstanceId, "instance", "i", "", "EC2 Instance ID, private or public IP address, or DNS name (choose interactively on the terminal when omitted)")
+	c.Flags().StringVar(&cpInstanceName, "name", "", "EC2 Instance Name tag")
+	c.Flags().StringArrayVar(&cpInstanceFilters, "filter", []string{}, "EC2 Instance filters NAME=VALUE[,NAME=VALUE...] (e.g. tag:Env=prod,tag:Role=jump)")
+	c.Flags().BoolVar(&cpChooseInstance, "choose", false, "Choose the instance when multiple instances match")
+	//
+	c.MarkFlagsMutuallyExclusive("instance", "name")
+	c.MarkFlagsMutuallyExclusive("instance", "filter")
+	// custom completion
+	c.RegisterFlagCompletionFunc("instance", invokeInstanceCompletion)
+}
+
+func validateInstanceFlags() error {
+	if !hasInstanceSelector() && !isTerminal() {
+		return errors.New("--instance, --name or --filter flag is required")
+	}
+	for _, f := range cpInstanceFilters {
+		_, err := ec2.ParseFilters(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasInstanceSelector() bool {
+	return cpInstanceId != "" || cpInstanceName != "" || len(cpInstanceFilters) > 0
+}
+
+func isTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// resolveInstanceId resolves the instance selector flags and sets the instance ID to cpInstanceId.
+// When no selector is specified, the instance is chosen with the interactive picker.
+// The choice list is written to stderr and the messages to logf, so that stdout is kept parsable.
+func resolveInstanceId(ec2api ec2.EC2API, ssmapi ssm.SSMAPI, ctx context.Context, logf func(format string, a ...any)) error {
+	if ec2.IsInstanceId(cpInstanceId) {
+		return nil
+	}
+	if !hasInstanceSelector() {
+		selected, err := pickInstance(ec2api, ssmapi, ctx, logf)
+		if err != nil {
+			return err
+		}
+		logf("Use instance %v\n", selected)
+		cpInstanceId = selected.InstanceId
+		return nil
+	}
+	var matches []ec2.InstanceSummary
+	var condition string
+	var err error
+	if cpInstanceId != "" {
+		condition = fmt.Sprintf("address %v", cpInstanceId)
+		matches, err = ec2.FindInstancesByAddress(ec2api, ctx, cpInstanceId)
+	} else {
+		filters := []types.Filter{}
+		conditions := []string{}
+		if cpInstanceName != "" {
+			filters = append(filters, ec2.NameFilter(cpInstanceName))
+			conditions = append(conditions, fmt.Sprintf("name %v", cpInstanceName))
+		}
+		for _, f := range cpInstanceFilters {
+			parsed, _ := ec2.ParseFilters(f)
+			filters = append(filters, parsed...)
+			conditions = append(conditions, fmt.Sprintf("filter %v", f))
+		}
+		condition = strings.Join(conditions, " and ")
+		matches, err = ec2.FindInstances(ec2api, ctx, filters)
+	}
+	if err != nil {
+		return err
+	}
+	selected, err := selectInstance(matches, condition, cpChooseInstance, os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+	logf("Use instance %v\n", selected)
+	cpInstanceId = selected.InstanceId
+	return nil
+}
+
+// selectInstance returns the only matched instance, or the instance chosen from multiple matches.
+func selectInstance(matches []ec2.InstanceSummary, condition string, choose bool, in io.Reader, out io.Writer) (ec2.InstanceSummary, error) {
+	switch {
+	case len(matches) == 0:
+		return ec2.InstanceSummary{}, fmt.Errorf("no instance matches %v", condition)
+	case len(matches) == 1:
+		return matches[0], nil
+	case !choose:
+		lines := []string{fmt.Sprintf("%v instances match %v. Use --choose flag or specify one of them with --instance flag", len(matches), condition)}
+		for _, m := range matches {
+			lines = append(lines, fmt.Sprintf("  %v", m))
+		}
+		return ec2.InstanceSummary{}, errors.New(strings.Join(lines, "\n"))
+	}
+
+	// choose from the list
+	for i, m := range matches {
+		fmt.Fprintf(out, "[%v] %v\n", i+1, m)
+	}
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Choose instance [1-%v]: ", len(matches))
+		line, err := reader.ReadString('\n')
+		n, convErr := strconv.Atoi(strings.TrimSpace(line))
+		if convErr == nil && n >= 1 && n <= len(matches) {
+			return matches[n-1], nil
+		}
+		if err != nil {
+			return ec2.InstanceSummary{}, errors.New("no instance is chosen")
+		}
+	}
+}
+
+// pickInstance lets the user choose the Windows instance on the terminal.
+func pickInstance(ec2api ec2.EC2API, ssmapi ssm.SSMAPI, ctx context.Context, logf func(format string, a ...any)) (ec2.InstanceSummary, error) {
+	if !isTerminal() {
+		return ec2.InstanceSummary{}, errors.New("--instance, --name or --filter flag is required")
+	}
+	statuses, err := getInstanceStatuses(ec2api, ssmapi, ctx, []types.Filter{ec2.WindowsFilter()}, logf)
+	if err != nil {
+		return ec2.InstanceSummary{}, err
+	}
+	if len(statuses) == 0 {
+		return ec2.InstanceSummary{}, errors.New("no Windows instance is found in the region")
+	}
+	header, items := formatInstanceStatuses(statuses)
+	p := &picker.Picker{Prompt: "Choose instance", Header: header, Items: items}
+	index, err := p.RunTerminal(os.Stdin, os.Stderr)
+	if err != nil {
+		if errors.Is(err, picker.ErrCanceled) {
+			return ec2.InstanceSummary{}, errors.New("no instance is chosen")
+		}
+		return ec2.InstanceSummary{}, err
+	}
+	return statuses[index].InstanceSummary, nil
+}
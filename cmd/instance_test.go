@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	awsssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+)
+
+type MockEC2API struct {
+	DescribeInstancesOutput                *awsec2.DescribeInstancesOutput
+	DescribeInstanceConnectEndpointsOutput *awsec2.DescribeInstanceConnectEndpointsOutput
+	GetPasswordDataOutput                  *awsec2.GetPasswordDataOutput
+	DescribeRegionsOutput                  *awsec2.DescribeRegionsOutput
+	DescribeInstanceStatusOutput           *awsec2.DescribeInstanceStatusOutput
+	StartInstancesOutput                   *awsec2.StartInstancesOutput
+	StopInstancesOutput                    *awsec2.StopInstancesOutput
+	Error                                  error
+}
+
+func (m *MockEC2API) DescribeInstances(ctx context.Context, params *awsec2.DescribeInstancesInput, optFns ...func(*awsec2.Options)) (*awsec2.DescribeInstancesOutput, error) {
+	return m.DescribeInstancesOutput, m.Error
+}
+
+func (m *MockEC2API) DescribeInstanceConnectEndpoints(ctx context.Context, params *awsec2.DescribeInstanceConnectEndpointsInput, optFns ...func(*awsec2.Options)) (*awsec2.DescribeInstanceConnectEndpointsOutput, error) {
+	return m.DescribeInstanceConnectEndpointsOutput, m.Error
+}
+
+func (m *MockEC2API) GetPasswordData(ctx context.Context, params *awsec2.GetPasswordDataInput, optFns ...func(*awsec2.Options)) (*awsec2.GetPasswordDataOutput, error) {
+	return m.GetPasswordDataOutput, m.Error
+}
+
+func (m *MockEC2API) DescribeRegions(ctx context.Context, params *awsec2.DescribeRegionsInput, optFns ...func(*awsec2.Options)) (*awsec2.DescribeRegionsOutput, error) {
+	return m.DescribeRegionsOutput, m.Error
+}
+
+func (m *MockEC2API) DescribeInstanceStatus(ctx context.Context, params *awsec2.DescribeInstanceStatusInput, optFns ...func(*awsec2.Options)) (*awsec2.DescribeInstanceStatusOutput, error) {
+	return m.DescribeInstanceStatusOutput, m.Error
+}
+
+func (m *MockEC2API) StartInstances(ctx context.Context, params *awsec2.StartInstancesInput, optFns ...func(*awsec2.Options)) (*awsec2.StartInstancesOutput, error) {
+	return m.StartInstancesOutput, m.Error
+}
+
+func (m *MockEC2API) StopInstances(ctx context.Context, params *awsec2.StopInstancesInput, optFns ...func(*awsec2.Options)) (*awsec2.StopInstancesOutput, error) {
+	return m.StopInstancesOutput, m.Error
+}
+
+type MockSSMAPI struct {
+	DescribeInstanceInformationOutput *awsssm.DescribeInstanceInformationOutput
+	StartSessionOutput                *awsssm.StartSessionOutput
+	TerminateSessionOutput            *awsssm.TerminateSessionOutput
+	Error                             error
+}
+
+func (m *MockSSMAPI) DescribeInstanceInformation(ctx context.Context, params *awsssm.DescribeInstanceInformationInput, optFns ...func(*awsssm.Options)) (*awsssm.DescribeInstanceInformationOutput, error) {
+	return m.DescribeInstanceInformationOutput, m.Error
+}
+
+func (m *MockSSMAPI) StartSession(ctx context.Context, params *awsssm.StartSessionInput, optFns ...func(*awsssm.Options)) (*awsssm.StartSessionOutput, error) {
+	return m.StartSessionOutput, m.Error
+}
+
+func (m *MockSSMAPI) TerminateSession(ctx context.Context, params *awsssm.TerminateSessionInput, optFns ...func(*awsssm.Options)) (*awsssm.TerminateSessionOutput, error) {
+	return m.TerminateSessionOutput, m.Error
+}
+
+func Test_selectInstance(t *testing.T) {
+	matches := []ec2.InstanceSummary{{InstanceId: "i-01234567890abcdef"}, {InstanceId: "i-0fedcba9876543210"}}
+	out := &bytes.Buffer{}
+
+	// no match
+	if _, err := selectInstance(nil, "name web", false, strings.NewReader(""), out); err == nil {
+		t.Error("Failed to detect no match")
+	}
+	// single match
+	if s, err := selectInstance(matches[:1], "name web", false, strings.NewReader(""), out); err != nil || s.InstanceId != matches[0].InstanceId {
+		t.Errorf("Failed to select the single match (%v, %v)", s, err)
+	}
+	// multiple matches
+	_, err := selectInstance(matches, "name web", false, strings.NewReader(""), out)
+	if err == nil || !strings.Contains(err.Error(), matches[1].InstanceId) {
+		t.Errorf("Error must list matched instances (%v)", err)
+	}
+	// choose
+	if s, err := selectInstance(matches, "name web", true, strings.NewReader("x\n2\n"), out); err != nil || s.InstanceId != matches[1].InstanceId {
+		t.Errorf("Failed to choose the instance (%v, %v)", s, err)
+	}
+	if _, err := selectInstance(matches, "name web", true, strings.NewReader("3\n"), out); err == nil {
+		t.Error("Failed to detect no choice")
+	}
+}
+
+func Test_validateInstanceFlags(t *testing.T) {
+	defer func(id, name string, filters []string) {
+		cpInstanceId, cpInstanceName, cpInstanceFilters = id, name, filters
+	}(cpInstanceId, cpInstanceName, cpInstanceFilters)
+
+	// no selector is allowed only on the terminal
+	cpInstanceId, cpInstanceName, cpInstanceFilters = "", "", []string{}
+	if err := validateInstanceFlags(); (err == nil) != isTerminal() {
+		t.Errorf("Invalid validation without instance selector (%v)", err)
+	}
+	cpInstanceFilters = []string{"tag:Env"}
+	if err := validateInstanceFlags(); err == nil {
+		t.Error("Failed to detect invalid filter")
+	}
+	cpInstanceFilters = []string{"tag:Env=prod"}
+	if err := validateInstanceFlags(); err != nil {
+		t.Errorf("Valid filter is error (%v)", err)
+	}
+}
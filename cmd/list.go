@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/aws/ssm"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+var (
+	listOutput       string
+	listAllPlatforms bool
+)
+
+// instanceStatus is the instance and its reachability.
+type instanceStatus struct {
+	ec2.InstanceSummary
+	SSMPingStatus string
+	EICEndpointId string
+}
+
+// PublicReachable reports whether the instance can be connected with public command.
+func (s instanceStatus) PublicReachable() bool {
+	return s.State == types.InstanceStateNameRunning && (s.PublicDnsName != "" || s.PublicIpAddress != "")
+}
+
+// SSMReachable reports whether the instance can be connected with ssm command.
+func (s instanceStatus) SSMReachable() bool {
+	return s.SSMPingStatus == string(ssmtypes.PingStatusOnline)
+}
+
+// EICEReachable reports whether the instance can be connected with eice command.
+func (s instanceStatus) EICEReachable() bool {
+	return s.State == types.InstanceStateNameRunning && s.EICEndpointId != ""
+}
+
+type listResult struct {
+	InstanceId       string          `json:"instanceId"`
+	Name             string          `json:"name"`
+	State            string          `json:"state"`
+	Platform         string          `json:"platform"`
+	PrivateIpAddress string          `json:"privateIpAddress"`
+	PublicIpAddress  string          `json:"publicIpAddress"`
+	PublicDnsName    string          `json:"publicDnsName"`
+	VpcId            string          `json:"vpcId"`
+	SSMPingStatus    string          `json:"ssmPingStatus"`
+	EICEndpointId    string          `json:"eiceEndpointId"`
+	Reachable        reachableResult `json:"reachable"`
+}
+
+type reachableResult struct {
+	Public bool `json:"public"`
+	SSM    bool `json:"ssm"`
+	EICE   bool `json:"eice"`
+}
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List EC2 instances and available connection modes",
+	Long: `List EC2 instances and available connection modes.
+public, ssm and eice columns show which command can connect to the instance.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		switch listOutput {
+		case outputTable, outputJSON, outputCSV:
+		default:
+			return fmt.Errorf("set output format to %v, %v or %v", outputTable, outputJSON, outputCSV)
+		}
+		for _, f := range cpInstanceFilters {
+			_, err := ec2.ParseFilters(f)
+			if err != nil {
+				return err
+			}
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		return validateEndpointURLFlags()
+	},
+	RunE: invokeListCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", outputTable, "Output format (table, json or csv)")
+	listCmd.Flags().BoolVar(&listAllPlatforms, "all", false, "List all instances including non-Windows instances")
+	listCmd.Flags().StringVar(&cpInstanceName, "name", "", "EC2 Instance Name tag")
+	listCmd.Flags().StringArrayVar(&cpInstanceFilters, "filter", []string{}, "EC2 Instance filters NAME=VALUE[,NAME=VALUE...] (e.g. tag:Env=prod,tag:Role=jump)")
+	listCmd.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
+	listCmd.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addAssumeRoleFlags(listCmd)
+	addEndpointURLFlags(listCmd)
+	// custom completion
+	listCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{outputTable, outputJSON, outputCSV}, cobra.ShellCompDirectiveNoFileComp))
+	listCmd.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	listCmd.RegisterFlagCompletionFunc("profile", invokeProfileCompletion)
+}
+
+func invokeListCommand(_ *cobra.Command, _ []string) error {
+	// get aws config
+	cfg, err := getAWSConfig(listPrintf)
+	if err != nil {
+		return err
+	}
+	ec2api := ec2.NewAPI(cfg)
+	ssmapi := ssm.NewAPI(cfg)
+	ctx := context.Background()
+
+	// validate region
+	err = validateRegion(ec2api, ctx, listPrintf)
+	if err != nil {
+		return err
+	}
+
+	filters := []types.Filter{}
+	if !listAllPlatforms {
+		filters = append(filters, ec2.WindowsFilter())
+	}
+	if cpInstanceName != "" {
+		filters = append(filters, ec2.NameFilter(cpInstanceName))
+	}
+	for _, f := range cpInstanceFilters {
+		parsed, _ := ec2.ParseFilters(f)
+		filters = append(filters, parsed...)
+	}
+	statuses, err := getInstanceStatuses(ec2api, ssmapi, ctx, filters, listPrintf)
+	if err != nil {
+		return err
+	}
+	return writeInstanceStatuses(os.Stdout, statuses, listOutput)
+}
+
+// getInstanceStatuses returns the instances matched with filters and their reachability.
+// EICE endpoints are described while describing instances, and SSM statuses are described in concurrent batches.
+// When EICE endpoints can't be described, the warning is written to logf and EICE is treated as unavailable.
+func getInstanceStatuses(ec2api ec2.EC2API, ssmapi ssm.SSMAPI, ctx context.Context, filters []types.Filter, logf func(format string, a ...any)) ([]instanceStatus, error) {
+	type endpointsResult struct {
+		endpointIds map[string]string
+		err         error
+	}
+	endpoints := make(chan endpointsResult, 1)
+	go func() {
+		endpointIds, err := ec2.GetEICEndpointIdsByVpc(ec2api, ctx)
+		endpoints <- endpointsResult{endpointIds, err}
+	}()
+
+	instances, err := ec2.FindInstances(ec2api, ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	instanceIds := []string{}
+	for _, i := range instances {
+		instanceIds = append(instanceIds, i.InstanceId)
+	}
+	pingStatuses, err := ssm.GetPingStatuses(ssmapi, ctx, instanceIds)
+	if err != nil {
+		return nil, err
+	}
+	endpointsRes := <-endpoints
+	if endpointsRes.err != nil {
+		// EICE is optional, so the instances are still listed without it
+		logf("Failed to describe EC2 Instance Connect Endpoints, treat EICE as unavailable (%v)\n", endpointsRes.err)
+	}
+
+	statuses := []instanceStatus{}
+	for _, i := range instances {
+		statuses = append(statuses, instanceStatus{
+			InstanceSummary: i,
+			SSMPingStatus:   string(pingStatuses[i.InstanceId]),
+			EICEndpointId:   endpointsRes.endpointIds[i.VpcId],
+		})
+	}
+	return statuses, nil
+}
+
+// formatInstanceStatuses formats the instance statuses as aligned columns.
+func formatInstanceStatuses(statuses []instanceStatus) (string, []string) {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tINSTANCE ID\tSTATE\tPRIVATE IP\tPUBLIC IP\tPUBLIC\tSSM\tEICE")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			orDash(s.Name), s.InstanceId, s.State, orDash(s.PrivateIpAddress), orDash(s.PublicIpAddress),
+			yesOrDash(s.PublicReachable()), yesOrDash(s.SSMReachable()), yesOrDash(s.EICEReachable()))
+	}
+	w.Flush()
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	return lines[0], lines[1:]
+}
+
+func writeInstanceStatuses(out io.Writer, statuses []instanceStatus, format string) error {
+	switch format {
+	case outputJSON:
+		results := []listResult{}
+		for _, s := range statuses {
+			results = append(results, newListResult(s))
+		}
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case outputCSV:
+		w := csv.NewWriter(out)
+		w.Write([]string{"InstanceId", "Name", "State", "Platform", "PrivateIpAddress", "PublicIpAddress", "PublicDnsName", "VpcId", "SSMPingStatus", "EICEndpointId", "Public", "SSM", "EICE"})
+		for _, s := range statuses {
+			r := newListResult(s)
+			w.Write([]string{r.InstanceId, r.Name, r.State, r.Platform, r.PrivateIpAddress, r.PublicIpAddress, r.PublicDnsName, r.VpcId, r.SSMPingStatus, r.EICEndpointId,
+				strconv.FormatBool(r.Reachable.Public), strconv.FormatBool(r.Reachable.SSM), strconv.FormatBool(r.Reachable.EICE)})
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		header, lines := formatInstanceStatuses(statuses)
+		fmt.Fprintln(out, header)
+		for _, l := range lines {
+			fmt.Fprintln(out, l)
+		}
+	}
+	return nil
+}
+
+func newListResult(s instanceStatus) listResult {
+	return listResult{
+		InstanceId:       s.InstanceId,
+		Name:             s.Name,
+		State:            string(s.State),
+		Platform:         s.Platform,
+		PrivateIpAddress: s.PrivateIpAddress,
+		PublicIpAddress:  s.PublicIpAddress,
+		PublicDnsName:    s.PublicDnsName,
+		VpcId:            s.VpcId,
+		SSMPingStatus:    s.SSMPingStatus,
+		EICEndpointId:    s.EICEndpointId,
+		Reachable: reachableResult{
+			Public: s.PublicReachable(),
+			SSM:    s.SSMReachable(),
+			EICE:   s.EICEReachable(),
+		},
+	}
+}
+
+func yesOrDash(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "-"
+}
+
+func orDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+// listPrintf prints messages to stderr in JSON and CSV formats to keep stdout parsable.
+func listPrintf(format string, a ...any) {
+	if listOutput != outputTable {
+		fmt.Fprintf(os.Stderr, format, a...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
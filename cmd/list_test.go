@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	awsssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+func newListMocks() (*MockEC2API, *MockSSMAPI) {
+	ec2mock := &MockEC2API{
+		DescribeInstancesOutput: &awsec2.DescribeInstancesOutput{
+			Reservations: []ec2types.Reservation{{Instances: []ec2types.Instance{
+				{
+					InstanceId:       aws.String("i-01234567890abcdef"),
+					State:            &ec2types.InstanceState{Name: ec2types.InstanceStateNameRunning},
+					PrivateIpAddress: aws.String("10.0.0.1"),
+					VpcId:            aws.String("vpc-12345678"),
+					Tags:             []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String("web01")}},
+				},
+				{
+					InstanceId:       aws.String("i-0fedcba9876543210"),
+					State:            &ec2types.InstanceState{Name: ec2types.InstanceStateNameRunning},
+					PrivateIpAddress: aws.String("10.1.0.1"),
+					PublicIpAddress:  aws.String("203.0.113.1"),
+					VpcId:            aws.String("vpc-87654321"),
+				},
+				{
+					InstanceId: aws.String("i-0123456789abcdef0"),
+					State:      &ec2types.InstanceState{Name: ec2types.InstanceStateNameStopped},
+					VpcId:      aws.String("vpc-87654321"),
+				},
+			}}},
+		},
+		DescribeInstanceConnectEndpointsOutput: &awsec2.DescribeInstanceConnectEndpointsOutput{
+			InstanceConnectEndpoints: []ec2types.Ec2InstanceConnectEndpoint{{InstanceConnectEndpointId: aws.String("eice-1234567890"), VpcId: aws.String("vpc-87654321")}},
+		},
+	}
+	ssmmock := &MockSSMAPI{
+		DescribeInstanceInformationOutput: &awsssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []ssmtypes.InstanceInformation{{InstanceId: aws.String("i-01234567890abcdef"), PingStatus: ssmtypes.PingStatusOnline}},
+		},
+	}
+	return ec2mock, ssmmock
+}
+
+func Test_getInstanceStatuses(t *testing.T) {
+	ec2mock, ssmmock := newListMocks()
+	statuses, err := getInstanceStatuses(ec2mock, ssmmock, context.Background(), nil, printProgress)
+	if err != nil || len(statuses) != 3 {
+		t.Fatalf("Failed to get instance statuses (%v, %v)", statuses, err)
+	}
+	tests := []struct {
+		public, ssm, eice bool
+	}{
+		{false, true, false},
+		{true, false, true},
+		{false, false, false},
+	}
+	for i, tt := range tests {
+		s := statuses[i]
+		if s.PublicReachable() != tt.public || s.SSMReachable() != tt.ssm || s.EICEReachable() != tt.eice {
+			t.Errorf("Invalid reachability of %v (public=%v, ssm=%v, eice=%v)", s.InstanceId, s.PublicReachable(), s.SSMReachable(), s.EICEReachable())
+		}
+	}
+	if statuses[1].EICEndpointId != "eice-1234567890" {
+		t.Errorf("Invalid EIC Endpoint ID %v", statuses[1].EICEndpointId)
+	}
+
+	// no instance
+	statuses, err = getInstanceStatuses(&MockEC2API{DescribeInstancesOutput: &awsec2.DescribeInstancesOutput{}, DescribeInstanceConnectEndpointsOutput: &awsec2.DescribeInstanceConnectEndpointsOutput{}}, ssmmock, context.Background(), nil, printProgress)
+	if err != nil || len(statuses) != 0 {
+		t.Errorf("Instance must not be found (%v, %v)", statuses, err)
+	}
+}
+
+// eiceErrorEC2API fails to describe EC2 Instance Connect Endpoints only.
+type eiceErrorEC2API struct {
+	*MockEC2API
+}
+
+func (m *eiceErrorEC2API) DescribeInstanceConnectEndpoints(ctx context.Context, params *awsec2.DescribeInstanceConnectEndpointsInput, optFns ...func(*awsec2.Options)) (*awsec2.DescribeInstanceConnectEndpointsOutput, error) {
+	return nil, errors.New("access denied")
+}
+
+func Test_getInstanceStatuses_EICEError(t *testing.T) {
+	ec2mock, ssmmock := newListMocks()
+	warnings := []string{}
+	logf := func(format string, a ...any) { warnings = append(warnings, fmt.Sprintf(format, a...)) }
+	statuses, err := getInstanceStatuses(&eiceErrorEC2API{ec2mock}, ssmmock, context.Background(), nil, logf)
+	if err != nil || len(statuses) != 3 {
+		t.Fatalf("EICE error must not fail (%v, %v)", statuses, err)
+	}
+	for _, s := range statuses {
+		if s.EICEReachable() {
+			t.Errorf("EICE of %v must be unavailable", s.InstanceId)
+		}
+	}
+	if !statuses[0].SSMReachable() || !statuses[1].PublicReachable() {
+		t.Errorf("Other reachability must be kept %+v", statuses)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "access denied") {
+		t.Errorf("Invalid warnings %q", warnings)
+	}
+}
+
+func Test_writeInstanceStatuses(t *testing.T) {
+	ec2mock, ssmmock := newListMocks()
+	statuses, _ := getInstanceStatuses(ec2mock, ssmmock, context.Background(), nil, printProgress)
+
+	// table
+	header, items := formatInstanceStatuses(statuses)
+	if !strings.HasPrefix(header, "NAME ") || len(items) != 3 {
+		t.Fatalf("Invalid format %q %q", header, items)
+	}
+	if strings.Fields(items[0])[0] != "web01" || strings.Fields(items[1])[0] != "-" {
+		t.Errorf("Invalid format %q", items)
+	}
+	if strings.Index(items[0], "i-") != strings.Index(items[1], "i-") {
+		t.Errorf("Columns are not aligned %q", items)
+	}
+
+	// json
+	out := &bytes.Buffer{}
+	if err := writeInstanceStatuses(out, statuses, outputJSON); err != nil {
+		t.Fatal(err)
+	}
+	results := []listResult{}
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil || len(results) != 3 {
+		t.Fatalf("Invalid JSON %v (%v)", out.String(), err)
+	}
+	if !results[0].Reachable.SSM || results[0].SSMPingStatus != "Online" || !results[1].Reachable.EICE {
+		t.Errorf("Invalid JSON %+v", results)
+	}
+
+	// csv
+	out.Reset()
+	if err := writeInstanceStatuses(out, statuses, outputCSV); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil || len(records) != 4 {
+		t.Fatalf("Invalid CSV %v (%v)", records, err)
+	}
+	if records[2][0] != "i-0fedcba9876543210" || records[2][10] != "true" || records[2][11] != "false" {
+		t.Errorf("Invalid CSV record %v", records[2])
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
-	"github.com/stknohg/ec2rdp/internal/aws"
 	"github.com/stknohg/ec2rdp/internal/aws/ec2"
 	"github.com/stknohg/ec2rdp/internal/connector"
 )
@@ -19,6 +18,22 @@ var publicCmd = &cobra.Command{
 	Short: "Connect to public EC2 instance",
 	Long:  `Connect to public EC2 instance`,
 	Args: func(cmd *cobra.Command, args []string) error {
+		if err := validateInstanceFlags(); err != nil {
+			return err
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		if err := validateEndpointURLFlags(); err != nil {
+			return err
+		}
+		if err := validateStartFlags(); err != nil {
+			return err
+		}
+		if cpStopOnExit && cpClientName == "rdpfile" {
+			// the instance would be stopped before the .rdp file is used
+			return errors.New("--stop-on-exit flag can not be used with rdpfile client")
+		}
 		if cpPemFile == "" && !cpUserPassword {
 			return errors.New("--pemfile or --password flag is requied")
 		}
@@ -41,44 +56,52 @@ var publicCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(publicCmd)
-	publicCmd.Flags().StringVarP(&cpInstanceId, "instance", "i", "", "EC2 instance ID")
-	publicCmd.Flags().StringVarP(&cpPemFile, "pemfile", "p", "", ".pem file path")
-	publicCmd.Flags().IntVar(&cpPort, "port", 3389, "RDP port no")
-	publicCmd.Flags().StringVar(&cpUserName, "user", "Administrator", "RDP username")
-	publicCmd.Flags().BoolVarP(&cpUserPassword, "password", "P", false, "RDP passowrd")
-	publicCmd.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
-	publicCmd.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addPublicFlags(publicCmd)
+	addClientFlags(publicCmd)
+	addDisplayFlags(publicCmd)
 	// original parameters
-	publicCmd.Flags().BoolVar(&publicNoWait, "nowait", false, "")
+	addPublicNoWaitFlags(publicCmd)
+}
+
+func addPublicNoWaitFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&publicNoWait, "nowait", false, "")
+	// the instance would be stopped while the client is running
+	c.MarkFlagsMutuallyExclusive("nowait", "stop-on-exit")
+}
+
+func addPublicFlags(c *cobra.Command) {
+	addInstanceFlags(c)
+	addStartFlags(c)
+	addWaitPasswordFlags(c)
+	c.Flags().StringVarP(&cpPemFile, "pemfile", "p", "", ".pem file path")
+	c.Flags().IntVar(&cpPort, "port", 3389, "RDP port no")
+	c.Flags().StringVar(&cpUserName, "user", "Administrator", "RDP username")
+	c.Flags().BoolVarP(&cpUserPassword, "password", "P", false, "RDP passowrd")
+	c.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
+	c.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addAssumeRoleFlags(c)
+	addEndpointURLFlags(c)
 	//
-	publicCmd.MarkFlagRequired("instance")
-	publicCmd.MarkFlagFilename("pemfile", "pem")
-	publicCmd.MarkFlagsMutuallyExclusive("pemfile", "password")
+	c.MarkFlagFilename("pemfile", "pem")
+	c.MarkFlagsMutuallyExclusive("pemfile", "password")
 	// custom completion
-	publicCmd.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("profile", invokeProfileCompletion)
 }
 
 func invokePublicCommand(_ *cobra.Command, _ []string) error {
-	// check if connector application installed
-	connector := connector.DefaultConnector{}
-	_, err := connector.IsInstalled()
-	if err != nil {
-		return err
-	}
-
-	// get aws config
-	cfg := aws.GetConfig(cpProfileName, cpRegionName)
-	ec2api := ec2.NewAPI(cfg)
-	ctx := context.Background()
-
-	// check instance exists
-	_, err = ec2.IsInstanceExist(ec2api, ctx, cpInstanceId)
+	ctx, p, cleanup, err := prepareInstance(readiness{PasswordData: !cpUserPassword})
+	defer cleanup()
 	if err != nil {
 		return err
 	}
+	return runPublicMode(ctx, p)
+}
 
+// runPublicMode connects to the public hostname of the prepared instance.
+func runPublicMode(ctx context.Context, p *preparedInstance) error {
 	// get public hostname
-	hostName, err := ec2.GetPublicHostName(ec2api, ctx, cpInstanceId)
+	hostName, err := ec2.GetPublicHostName(p.ec2api, ctx, p.instanceId)
 	if err != nil {
 		return err
 	}
@@ -90,7 +113,7 @@ func invokePublicCommand(_ *cobra.Command, _ []string) error {
 	fmt.Printf("Remote host %v port %v is open\n", hostName, cpPort)
 
 	// get administrator password
-	password, message, err := getAdministratorPasswordWithPrompt(ec2api, ctx, cpInstanceId, cpPemFile, cpUserPassword)
+	password, message, err := getAdministratorPasswordWithPrompt(p.ec2api, ctx, p.instanceId, cpPemFile, cpUserPassword)
 	if err != nil {
 		return err
 	}
@@ -99,23 +122,19 @@ func invokePublicCommand(_ *cobra.Command, _ []string) error {
 	}
 
 	// connect
-	connector.HostName = hostName
-	connector.Port = cpPort
-	connector.UserName = cpUserName
-	connector.PlainPassword = password
-	connector.WaitFor = !publicNoWait
-	return connectPublicInstance(&connector)
+	p.param.HostName = hostName
+	p.param.Port = cpPort
+	p.param.UserName = cpUserName
+	p.param.PlainPassword = password
+	p.param.WaitFor = publicWaitFor(p.con)
+	return runConnector(ctx, p.con)
 }
 
-func connectPublicInstance(con connector.Connector) error {
-	err := con.PreConnect()
-	if err != nil {
-		return err
-	}
-	err = con.Connect()
-	if err != nil {
-		return err
+// publicWaitFor reports whether to wait for the client to exit.
+// The .rdp file is used after ec2rdp exits, because no tunnel needs to be kept open.
+func publicWaitFor(con connector.Connector) bool {
+	if _, ok := con.(*connector.RDPFileConnector); ok {
+		return false
 	}
-	defer con.PostConnect()
-	return nil
+	return !publicNoWait
 }
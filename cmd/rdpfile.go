@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rdpfileOutput       string
+	rdpfileShowPassword bool
+)
+
+// rdpfileCmd represents the rdpfile command
+var rdpfileCmd = &cobra.Command{
+	Use:   "rdpfile",
+	Short: "Generate Remote Desktop connection file (.rdp)",
+	Long: `Generate Remote Desktop connection file (.rdp).
+In ssm and eice mode, the tunnel is kept open until Enter is pressed.`,
+}
+
+var rdpfilePublicCmd = &cobra.Command{
+	Use:   "public",
+	Short: "Generate .rdp file for public EC2 instance",
+	Long:  `Generate .rdp file for public EC2 instance`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cpStopOnExit {
+			// the instance would be stopped before the .rdp file is used
+			return errors.New("--stop-on-exit flag can not be used with rdpfile public command")
+		}
+		return publicCmd.Args(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useRDPFileClient()
+		return invokePublicCommand(cmd, args)
+	},
+}
+
+var rdpfileSSMCmd = &cobra.Command{
+	Use:   "ssm",
+	Short: "Generate .rdp file for EC2 instance via SSM Session Manager",
+	Long:  `Generate .rdp file for EC2 instance via SSM Session Manager`,
+	Args:  ssmCmd.Args,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useRDPFileClient()
+		return invokeSSMCommand(cmd, args)
+	},
+}
+
+var rdpfileEICECmd = &cobra.Command{
+	Use:   "eice",
+	Short: "Generate .rdp file for EC2 instance via EC2 Instance Connect Endpoint",
+	Long:  `Generate .rdp file for EC2 instance via EC2 Instance Connect Endpoint`,
+	Args:  eiceCmd.Args,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useRDPFileClient()
+		return invokeEICECommand(cmd, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rdpfileCmd)
+	rdpfileCmd.AddCommand(rdpfilePublicCmd)
+	rdpfileCmd.AddCommand(rdpfileSSMCmd)
+	rdpfileCmd.AddCommand(rdpfileEICECmd)
+	addPublicFlags(rdpfilePublicCmd)
+	addSSMFlags(rdpfileSSMCmd)
+	addEICEFlags(rdpfileEICECmd)
+	for _, c := range rdpfileCmd.Commands() {
+		addDisplayFlags(c)
+	}
+	rdpfileCmd.PersistentFlags().StringVarP(&rdpfileOutput, "output", "o", "", ".rdp file path (default: <instance ID>.rdp)")
+	rdpfileCmd.PersistentFlags().BoolVar(&rdpfileShowPassword, "show-password", false, "Show RDP password")
+	//
+	rdpfileCmd.MarkPersistentFlagFilename("output", "rdp")
+}
+
+func useRDPFileClient() {
+	cpClientName = "rdpfile"
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws"
+)
+
+const (
+	minRoleDuration = 15 * time.Minute
+	maxRoleDuration = 12 * time.Hour
+	// AWS limits the role chaining session to 1 hour
+	maxChainedRoleDuration = time.Hour
+)
+
+// Assume role parameters
+var (
+	cpRoleArns        []string
+	cpExternalId      string
+	cpRoleSessionName string
+	cpMFASerial       string
+	cpRoleDuration    time.Duration
+)
+
+func addAssumeRoleFlags(c *cobra.Command) {
+	c.Flags().StringArrayVar(&cpRoleArns, "role-arn", []string{}, "IAM role ARN to assume. Can be specified multiple times to chain roles")
+	c.Flags().StringVar(&cpExternalId, "external-id", "", "External ID to assume the (last) role")
+	c.Flags().StringVar(&cpRoleSessionName, "role-session-name", "", "Role session name (default: ec2rdp-TIMESTAMP)")
+	c.Flags().StringVar(&cpMFASerial, "mfa-serial", "", "MFA device serial number or ARN to assume the (first) role")
+	c.Flags().DurationVar(&cpRoleDuration, "duration", 0, "Duration of the role session between 15m and 12h (default: 1h)")
+}
+
+func validateAssumeRoleFlags() error {
+	if len(cpRoleArns) == 0 {
+		if cpExternalId != "" || cpRoleSessionName != "" || cpMFASerial != "" || cpRoleDuration != 0 {
+			return errors.New("--external-id, --role-session-name, --mfa-serial and --duration flags require --role-arn flag")
+		}
+		return nil
+	}
+	if cpRoleDuration != 0 && (cpRoleDuration < minRoleDuration || cpRoleDuration > maxRoleDuration) {
+		return errors.New("set duration between 15m and 12h")
+	}
+	if len(cpRoleArns) > 1 && cpRoleDuration > maxChainedRoleDuration {
+		return errors.New("set duration 1h or less when chaining roles")
+	}
+	return nil
+}
+
+func getAssumeRoleOptions() aws.AssumeRoleOptions {
+	return aws.AssumeRoleOptions{
+		RoleArns:        cpRoleArns,
+		ExternalId:      cpExternalId,
+		RoleSessionName: cpRoleSessionName,
+		MFASerial:       cpMFASerial,
+		Duration:        cpRoleDuration,
+		Cache:           aws.DefaultRoleCache(),
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_validateAssumeRoleFlags(t *testing.T) {
+	defer func(roleArns []string, externalId string, duration time.Duration) {
+		cpRoleArns, cpExternalId, cpRoleDuration = roleArns, externalId, duration
+	}(cpRoleArns, cpExternalId, cpRoleDuration)
+
+	cpRoleArns, cpExternalId, cpRoleDuration = []string{}, "", 0
+	if err := validateAssumeRoleFlags(); err != nil {
+		t.Errorf("No role is error (%v)", err)
+	}
+	cpExternalId = "external"
+	if err := validateAssumeRoleFlags(); err == nil {
+		t.Error("Failed to detect --external-id without --role-arn")
+	}
+	cpRoleArns = []string{"arn:aws:iam::123456789012:role/role1", "arn:aws:iam::123456789012:role/role2"}
+	if err := validateAssumeRoleFlags(); err != nil {
+		t.Errorf("Valid roles are error (%v)", err)
+	}
+	cpRoleDuration = 5 * time.Minute
+	if err := validateAssumeRoleFlags(); err == nil {
+		t.Error("Failed to detect too short duration")
+	}
+	cpRoleDuration = 13 * time.Hour
+	if err := validateAssumeRoleFlags(); err == nil {
+		t.Error("Failed to detect too long duration")
+	}
+
+	// chained roles
+	cpRoleDuration = time.Hour
+	if err := validateAssumeRoleFlags(); err != nil {
+		t.Errorf("1h duration of chained roles is error (%v)", err)
+	}
+	cpRoleDuration = 2 * time.Hour
+	if err := validateAssumeRoleFlags(); err == nil {
+		t.Error("Failed to detect too long duration of chained roles")
+	}
+	cpRoleArns = cpRoleArns[:1]
+	if err := validateAssumeRoleFlags(); err != nil {
+		t.Errorf("2h duration of single role is error (%v)", err)
+	}
+}
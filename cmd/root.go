@@ -3,8 +3,12 @@ package cmd
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/connector"
 )
 
 // Common parameters
@@ -16,6 +20,24 @@ var (
 	cpUserPassword bool
 	cpProfileName  string
 	cpRegionName   string
+	cpClientName   string
+	// password parameters
+	cpWaitPassword        bool
+	cpWaitPasswordTimeout time.Duration
+	// tunnel parameters
+	cpReconnectAttempts int
+	// custom client parameters
+	cpClientCommand     string
+	cpClientPasswordVia string
+	// display and redirection parameters
+	cpFullScreen     bool
+	cpWindowSize     string
+	cpMultiMonitor   bool
+	cpClipboard      bool
+	cpDrives         bool
+	cpPrinters       bool
+	cpAudio          string
+	cpKeyboardLayout string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -39,6 +61,35 @@ func init() {
 	// do nothing
 }
 
+func addClientFlags(c *cobra.Command) {
+	c.Flags().StringVar(&cpClientName, "client", "", "RDP client name (default: first installed client)")
+	c.Flags().StringVar(&cpClientCommand, "client-command", "", "Custom client command template (e.g. \"myclient --host {host} --port {port} --user {user}\")")
+	c.Flags().StringVar(&cpClientPasswordVia, "client-password-via", connector.PasswordViaStdin, "How to pass password to custom client (stdin, env or file)")
+	// custom completion
+	c.RegisterFlagCompletionFunc("client", invokeClientCompletion)
+	c.RegisterFlagCompletionFunc("client-password-via", cobra.FixedCompletions([]string{connector.PasswordViaStdin, connector.PasswordViaEnv, connector.PasswordViaFile}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func addWaitPasswordFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&cpWaitPassword, "wait-password", false, "Wait until EC2 PasswordData is generated after launch")
+	c.Flags().DurationVar(&cpWaitPasswordTimeout, "wait-password-timeout", 15*time.Minute, "Timeout of --wait-password flag")
+}
+
+func addDisplayFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&cpFullScreen, "fullscreen", true, "Start in full screen mode")
+	c.Flags().StringVar(&cpWindowSize, "size", "", "Window size WIDTHxHEIGHT (e.g. 1280x800). Disable full screen mode")
+	c.Flags().BoolVar(&cpMultiMonitor, "multimon", false, "Use all monitors")
+	c.Flags().BoolVar(&cpClipboard, "clipboard", true, "Redirect clipboard")
+	c.Flags().BoolVar(&cpDrives, "drives", false, "Redirect local drives")
+	c.Flags().BoolVar(&cpPrinters, "printers", false, "Redirect printers")
+	c.Flags().StringVar(&cpAudio, "audio", connector.AudioLocal, "Audio playback (local, remote or off)")
+	c.Flags().StringVar(&cpKeyboardLayout, "keyboard-layout", "", "Keyboard layout ID (e.g. 0x00000411)")
+	//
+	c.MarkFlagsMutuallyExclusive("fullscreen", "size")
+	// custom completion
+	c.RegisterFlagCompletionFunc("audio", cobra.FixedCompletions([]string{connector.AudioLocal, connector.AudioRemote, connector.AudioOff}, cobra.ShellCompDirectiveNoFileComp))
+}
+
 // Common validations
 func validatePemFile(filePath string) error {
 	if filePath == "" {
@@ -57,3 +108,38 @@ func validatePort(portNo int) error {
 	}
 	return nil
 }
+
+func getDisplayOptions() (connector.DisplayOptions, error) {
+	options := connector.DisplayOptions{
+		FullScreen:   cpFullScreen,
+		MultiMonitor: cpMultiMonitor,
+		Clipboard:    cpClipboard,
+		Drives:       cpDrives,
+		Printers:     cpPrinters,
+		Audio:        cpAudio,
+	}
+	if cpWindowSize != "" {
+		width, height, found := strings.Cut(strings.ToLower(cpWindowSize), "x")
+		w, werr := strconv.Atoi(width)
+		h, herr := strconv.Atoi(height)
+		if !found || werr != nil || herr != nil || w <= 0 || h <= 0 {
+			return options, errors.New("set window size as WIDTHxHEIGHT (e.g. 1280x800)")
+		}
+		options.FullScreen = false
+		options.Width = w
+		options.Height = h
+	}
+	switch cpAudio {
+	case connector.AudioLocal, connector.AudioRemote, connector.AudioOff:
+	default:
+		return options, errors.New("set audio playback to local, remote or off")
+	}
+	if cpKeyboardLayout != "" {
+		layout, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(cpKeyboardLayout), "0x"), 16, 32)
+		if err != nil || layout == 0 {
+			return options, errors.New("set keyboard layout ID in hexadecimal (e.g. 0x00000411)")
+		}
+		options.KeyboardLayout = uint32(layout)
+	}
+	return options, nil
+}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/stknohg/ec2rdp/internal/connector"
 )
 
 func Test_validatePemFile(t *testing.T) {
@@ -54,3 +56,42 @@ func Test_validatePort(t *testing.T) {
 		}
 	}
 }
+
+func Test_getDisplayOptions(t *testing.T) {
+	defer func() {
+		cpWindowSize, cpAudio, cpKeyboardLayout = "", connector.AudioLocal, ""
+	}()
+	cases := []struct {
+		Size           string
+		Audio          string
+		KeyboardLayout string
+		Valid          bool
+	}{
+		{"", connector.AudioLocal, "", true},
+		{"1280x800", connector.AudioRemote, "0x00000411", true},
+		{"1280X800", connector.AudioOff, "411", true},
+		{"1280", connector.AudioLocal, "", false},
+		{"0x800", connector.AudioLocal, "", false},
+		{"", "speaker", "", false},
+		{"", connector.AudioLocal, "jp", false},
+	}
+	for _, c := range cases {
+		cpFullScreen, cpWindowSize, cpAudio, cpKeyboardLayout = true, c.Size, c.Audio, c.KeyboardLayout
+		options, err := getDisplayOptions()
+		if !c.Valid {
+			if err == nil {
+				t.Errorf("%+v is invalid", c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v is valid", c)
+		}
+		if c.Size != "" && (options.FullScreen || options.Width != 1280 || options.Height != 800) {
+			t.Errorf("Window size %v must disable full screen mode", c.Size)
+		}
+		if c.KeyboardLayout != "" && options.KeyboardLayout != 0x411 {
+			t.Errorf("Invalid keyboard layout %v", c.KeyboardLayout)
+		}
+	}
+}
@@ -5,14 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/stknohg/ec2rdp/internal/aws"
 	"github.com/stknohg/ec2rdp/internal/aws/ec2"
 	"github.com/stknohg/ec2rdp/internal/aws/ssm"
-	"github.com/stknohg/ec2rdp/internal/connector"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
+)
+
+var (
+	ssmRemoteHost       string
+	ssmRemoteInstanceId string
 )
 
 // ssmCmd represents the ssm command
@@ -21,7 +23,16 @@ var ssmCmd = &cobra.Command{
 	Short: "Connect to EC2 instance via SSM Session Manager",
 	Long:  `Connect to EC2 instance via SSM Session Manager`,
 	Args: func(cmd *cobra.Command, args []string) error {
-		if installed, err := isSessionManagerPluginInstalled(); !installed {
+		if err := validateInstanceFlags(); err != nil {
+			return err
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		if err := validateEndpointURLFlags(); err != nil {
+			return err
+		}
+		if err := validateStartFlags(); err != nil {
 			return err
 		}
 		if cpPemFile == "" && !cpUserPassword {
@@ -46,49 +57,63 @@ var ssmCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(ssmCmd)
-	ssmCmd.Flags().StringVarP(&cpInstanceId, "instance", "i", "", "EC2 Instance ID")
-	ssmCmd.Flags().StringVarP(&cpPemFile, "pemfile", "p", "", ".pem file path")
-	ssmCmd.Flags().IntVar(&cpPort, "port", 3389, "RDP port no")
-	ssmCmd.Flags().StringVar(&cpUserName, "user", "Administrator", "RDP username")
-	ssmCmd.Flags().BoolVarP(&cpUserPassword, "password", "P", false, "RDP passowrd")
-	ssmCmd.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
-	ssmCmd.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addSSMFlags(ssmCmd)
+	addClientFlags(ssmCmd)
+	addDisplayFlags(ssmCmd)
+}
+
+func addSSMFlags(c *cobra.Command) {
+	addInstanceFlags(c)
+	addStartFlags(c)
+	addWaitPasswordFlags(c)
+	c.Flags().StringVarP(&cpPemFile, "pemfile", "p", "", ".pem file path")
+	c.Flags().IntVar(&cpPort, "port", 3389, "RDP port no")
+	c.Flags().StringVar(&cpUserName, "user", "Administrator", "RDP username")
+	c.Flags().BoolVarP(&cpUserPassword, "password", "P", false, "RDP passowrd")
+	c.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
+	c.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addAssumeRoleFlags(c)
+	addEndpointURLFlags(c)
+	c.Flags().IntVar(&cpReconnectAttempts, "reconnect", 5, "Max reconnect attempts when the tunnel is disconnected (0 to disable)")
+	c.Flags().StringVar(&ssmRemoteHost, "remote-host", "", "Remote host name or IP address to connect through the instance")
+	c.Flags().StringVar(&ssmRemoteInstanceId, "remote-instance", "", "Remote EC2 Instance ID to connect through the instance")
 	//
-	ssmCmd.MarkFlagRequired("instance")
-	ssmCmd.MarkFlagFilename("pemfile", "pem")
-	ssmCmd.MarkFlagsMutuallyExclusive("pemfile", "password")
+	c.MarkFlagFilename("pemfile", "pem")
+	c.MarkFlagsMutuallyExclusive("pemfile", "password")
+	c.MarkFlagsMutuallyExclusive("remote-host", "remote-instance")
 	// custom completion
-	ssmCmd.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("profile", invokeProfileCompletion)
 }
 
 func invokeSSMCommand(_ *cobra.Command, _ []string) error {
-	// check if connector application installed
-	connector := connector.DefaultConnector{}
-	_, err := connector.IsInstalled()
+	ctx, p, cleanup, err := prepareInstance(readiness{PasswordData: !cpUserPassword && ssmRemoteHost == "" && ssmRemoteInstanceId == "", SSMOnline: true})
+	defer cleanup()
 	if err != nil {
 		return err
 	}
+	return runSSMMode(ctx, p)
+}
 
-	// get aws config
-	cfg := aws.GetConfig(cpProfileName, cpRegionName)
-	ec2api := ec2.NewAPI(cfg)
-	ssmapi := ssm.NewAPI(cfg)
-	ctx := context.Background()
-
-	// check instance exists
-	_, err = ec2.IsInstanceExist(ec2api, ctx, cpInstanceId)
+// runSSMMode connects to the prepared instance or the remote host through SSM Session Manager port forwarding.
+func runSSMMode(ctx context.Context, p *preparedInstance) error {
+	// check instance status
+	_, err := ssm.IsInstanceOnline(p.ssmapi, ctx, p.instanceId)
 	if err != nil {
 		return err
 	}
 
-	// check instance status
-	_, err = ssm.IsInstanceOnline(ssmapi, ctx, cpInstanceId)
+	// get remote host
+	targetInstanceId, remoteHost, err := getSSMRemoteTarget(p.ec2api, ctx)
 	if err != nil {
 		return err
 	}
+	if remoteHost != "" {
+		fmt.Printf("Connect to remote host %v through %v\n", remoteHost, p.instanceId)
+	}
 
-	// get administrator password
-	password, message, err := getAdministratorPasswordWithPrompt(ec2api, ctx, cpInstanceId, cpPemFile, cpUserPassword)
+	// get administrator password of the target instance
+	password, message, err := getAdministratorPasswordWithPrompt(p.ec2api, ctx, targetInstanceId, cpPemFile, cpUserPassword)
 	if err != nil {
 		return err
 	}
@@ -103,64 +128,53 @@ func invokeSSMCommand(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
-	// start port forwarding with SSM Session Manager Plugin
-	var ssmRegion = cfg.Region
-	var ssmProfile = getSSMProfileName(cpProfileName)
-	ssmResult, err := ssm.StartSSMSessionPortForward(ssmapi, ctx, cpInstanceId, cpPort, localPort, "ec2rdp ssm", ssmRegion, ssmProfile)
+	// start port forwarding with SSM Session Manager
+	session := newReconnectingTunnel(func() tunnel.Tunnel {
+		if remoteHost != "" {
+			return withStreamURL(p.cfg, ssm.NewSSMSessionPortForwardToRemoteHost(p.ssmapi, p.instanceId, remoteHost, cpPort, localPort, "ec2rdp ssm"))
+		}
+		return withStreamURL(p.cfg, ssm.NewSSMSessionPortForward(p.ssmapi, p.instanceId, cpPort, localPort, "ec2rdp ssm"))
+	})
+	err = openTunnel(ctx, session)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Starting session with SessionId: %v\n", ssmResult.SessionId)
-	for i := 1; ; i++ {
-		if isPortOpen(localHostName, localPort) {
-			break
-		}
-		time.Sleep(500 * time.Millisecond)
-		if i >= 10 {
-			return fmt.Errorf("%v port %v is not open", localHostName, localPort)
-		}
-	}
+	fmt.Printf("Starting %v\n", session)
 	fmt.Printf("Start listening %v:%v\n", localHostName, localPort)
 
 	// connect
-	connector.HostName = localHostName
-	connector.Port = localPort
-	connector.UserName = cpUserName
-	connector.PlainPassword = password
-	connector.WaitFor = true // always true
-	return connectSSMInstance(&connector, ssmResult)
+	p.param.HostName = localHostName
+	p.param.Port = localPort
+	p.param.UserName = cpUserName
+	p.param.PlainPassword = password
+	p.param.WaitFor = true // always true
+	return connectTunnelInstance(ctx, p.con, session)
 }
 
-func getSSMProfileName(input string) string {
-	if input != "" {
-		return input
-	}
-	return os.Getenv("AWS_PROFILE")
-}
-
-func isSessionManagerPluginInstalled() (bool, error) {
-	_, err := exec.LookPath("session-manager-plugin")
-	if err != nil {
-		// ref : https://github.com/aws/aws-cli/blob/2.11.16/awscli/customizations/sessionmanager.py#L23-L28
-		return false, errors.New(`SessionManagerPlugin is not found.
-Please refer to SessionManager Documentation here: https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-troubleshooting.html#plugin-not-found`)
-	}
-	return true, nil
-}
-
-func connectSSMInstance(con connector.Connector, ret *ssm.StartSSMSessionPluginResult) error {
-	err := con.PreConnect()
-	if err != nil {
-		return err
+// getSSMRemoteTarget returns the instance ID to decrypt password and the remote host name.
+// The remote host name is empty when connecting to the instance itself.
+func getSSMRemoteTarget(ec2api ec2.EC2API, ctx context.Context) (string, string, error) {
+	if ssmRemoteInstanceId != "" {
+		ip, err := ec2.GetPrivateIpAddress(ec2api, ctx, ssmRemoteInstanceId)
+		if err != nil {
+			return "", "", err
+		}
+		return ssmRemoteInstanceId, ip, nil
 	}
-	err = con.Connect()
-	if err != nil {
-		return err
+	if ssmRemoteHost != "" {
+		if cpUserPassword {
+			// password is not decrypted
+			return "", ssmRemoteHost, nil
+		}
+		matches, err := ec2.FindInstancesByAddress(ec2api, ctx, ssmRemoteHost)
+		if err != nil {
+			return "", "", err
+		}
+		selected, err := selectInstance(matches, fmt.Sprintf("remote host %v", ssmRemoteHost), false, os.Stdin, os.Stdout)
+		if err != nil {
+			return "", "", fmt.Errorf("%w\nUse --remote-instance or --password flag instead", err)
+		}
+		return selected.InstanceId, ssmRemoteHost, nil
 	}
-	defer func() {
-		con.PostConnect()
-		fmt.Printf("Terminate SSM session%v\n", ret.SessionId)
-		ssm.TerminateSSMSession(ret.API, context.Background(), ret.SessionId)
-	}()
-	return nil
+	return cpInstanceId, "", nil
 }
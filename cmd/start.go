@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/aws/ssm"
+	"github.com/stknohg/ec2rdp/internal/connector"
+	"github.com/stknohg/ec2rdp/internal/wait"
+)
+
+const (
+	startTimeout      = 15 * time.Minute
+	startPollInterval = 5 * time.Second
+)
+
+// Instance start parameters
+var (
+	cpStartInstance bool
+	cpStopOnExit    bool
+)
+
+// for testing
+var newStartPoller = func() *wait.Poller {
+	return &wait.Poller{Interval: startPollInterval, Timeout: startTimeout, Logf: printProgress}
+}
+
+func addStartFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&cpStartInstance, "start", false, "Start the stopped (or hibernated) instance and wait until it is ready")
+	c.Flags().BoolVar(&cpStopOnExit, "stop-on-exit", false, "Stop the instance started by --start flag when the client exits")
+}
+
+func validateStartFlags() error {
+	if cpStopOnExit && !cpStartInstance {
+		return errors.New("--stop-on-exit flag requires --start flag")
+	}
+	return nil
+}
+
+// readiness is the condition to wait for after starting the instance.
+type readiness struct {
+	PasswordData bool // wait for PasswordData to decrypt the administrator password
+	SSMOnline    bool // wait for SSM PingStatus Online when the instance is managed by SSM
+}
+
+// preparedInstance is the AWS config, the API clients, the connector and the instance shared by the connection modes.
+type preparedInstance struct {
+	cfg        awssdk.Config
+	ec2api     ec2.EC2API
+	ssmapi     ssm.SSMAPI
+	instanceId string
+	con        connector.Connector
+	param      *connector.DefaultConnector
+}
+
+// prepareInstance creates the API clients and the connector, resolves the instance and starts it if needed.
+// The returned context is canceled by Ctrl-C. The returned function stops the instance started by ec2rdp and must be called on exit, even if an error is returned.
+func prepareInstance(ready readiness) (context.Context, *preparedInstance, func(), error) {
+	ctx := context.Background()
+	cleanup := func() {}
+
+	// get aws config
+	cfg, err := getAWSConfig(printProgress)
+	if err != nil {
+		return ctx, nil, cleanup, err
+	}
+	p := &preparedInstance{cfg: cfg, ec2api: ec2.NewAPI(cfg), ssmapi: ssm.NewAPI(cfg), param: &connector.DefaultConnector{}}
+
+	// validate region
+	err = validateRegion(p.ec2api, ctx, printProgress)
+	if err != nil {
+		return ctx, nil, cleanup, err
+	}
+
+	// resolve instance
+	err = resolveInstanceId(p.ec2api, p.ssmapi, ctx, printProgress)
+	if err != nil {
+		return ctx, nil, cleanup, err
+	}
+	p.instanceId = cpInstanceId
+
+	// check if connector application installed
+	p.con, err = newConnector(cpClientName, p.param)
+	if err != nil {
+		return ctx, nil, cleanup, err
+	}
+
+	// release the instance and the connection on Ctrl-C
+	ctx, stop := newSignalContext(ctx)
+	cleanup = stop
+
+	// check instance exists
+	_, err = ec2.IsInstanceExist(p.ec2api, ctx, p.instanceId)
+	if err != nil {
+		return ctx, nil, cleanup, err
+	}
+
+	// start instance if needed
+	started, err := ensureInstanceRunning(p.ec2api, p.ssmapi, ctx, p.instanceId, ready)
+	cleanup = func() {
+		stopInstanceOnExit(p.ec2api, p.instanceId, started)
+		stop()
+	}
+	if err != nil {
+		return ctx, nil, cleanup, err
+	}
+	return ctx, p, cleanup, nil
+}
+
+// ensureInstanceRunning starts the instance when --start flag is specified, and waits until it is ready.
+// It returns true when the instance is started by this function.
+func ensureInstanceRunning(ec2api ec2.EC2API, ssmapi ssm.SSMAPI, ctx context.Context, instanceId string, ready readiness) (bool, error) {
+	state, err := ec2.GetInstanceState(ec2api, ctx, instanceId)
+	if err != nil {
+		return false, err
+	}
+	if state == types.InstanceStateNameRunning {
+		return false, nil
+	}
+	if !cpStartInstance {
+		if state == types.InstanceStateNameStopped || state == types.InstanceStateNameStopping || state == types.InstanceStateNamePending {
+			return false, fmt.Errorf("instance %v is %v. Use --start flag to start the instance", instanceId, state)
+		}
+		return false, fmt.Errorf("instance %v is %v", instanceId, state)
+	}
+
+	if ready.SSMOnline {
+		// SSM Agent of the instance not managed by SSM never becomes online
+		statuses, err := ssm.GetPingStatuses(ssmapi, ctx, []string{instanceId})
+		if err != nil {
+			return false, err
+		}
+		if statuses[instanceId] == "" {
+			fmt.Printf("Instance %v is not managed by SSM. Skip waiting for SSM Agent\n", instanceId)
+			ready.SSMOnline = false
+		}
+	}
+
+	poller := newStartPoller()
+	started := false
+	switch state {
+	case types.InstanceStateNameStopping:
+		err = waitInstanceState(ec2api, poller, ctx, instanceId, types.InstanceStateNameStopped)
+		if err != nil {
+			return false, err
+		}
+		fallthrough
+	case types.InstanceStateNameStopped:
+		fmt.Printf("Starting instance %v\n", instanceId)
+		err = ec2.StartInstance(ec2api, ctx, instanceId)
+		if err != nil {
+			return false, err
+		}
+		started = true
+	case types.InstanceStateNamePending:
+		// wait for running
+	default:
+		return false, fmt.Errorf("instance %v is %v and can not be started", instanceId, state)
+	}
+
+	// wait until RDP is usable
+	err = waitInstanceState(ec2api, poller, ctx, instanceId, types.InstanceStateNameRunning)
+	if err != nil {
+		return started, err
+	}
+	err = poller.Until(ctx, "status checks", func(ctx context.Context) (bool, string, error) {
+		instanceStatus, systemStatus, err := ec2.GetInstanceStatusChecks(ec2api, ctx, instanceId)
+		if err != nil {
+			return false, "", err
+		}
+		return instanceStatus == types.SummaryStatusOk && systemStatus == types.SummaryStatusOk,
+			fmt.Sprintf("instance %v, system %v", instanceStatus, systemStatus), nil
+	})
+	if err != nil {
+		return started, err
+	}
+	if ready.PasswordData {
+		err = waitPasswordData(ec2api, poller, ctx, instanceId)
+		if err != nil {
+			return started, err
+		}
+	}
+	if ready.SSMOnline {
+		err = poller.Until(ctx, "SSM Agent online", func(ctx context.Context) (bool, string, error) {
+			statuses, err := ssm.GetPingStatuses(ssmapi, ctx, []string{instanceId})
+			if err != nil {
+				return false, "", err
+			}
+			status := statuses[instanceId]
+			return status == ssmtypes.PingStatusOnline, string(status), nil
+		})
+		if err != nil {
+			return started, err
+		}
+	}
+	fmt.Printf("Instance %v is ready\n", instanceId)
+	return started, nil
+}
+
+func waitInstanceState(ec2api ec2.EC2API, poller *wait.Poller, ctx context.Context, instanceId string, expected types.InstanceStateName) error {
+	return poller.Until(ctx, fmt.Sprintf("instance %v", expected), func(ctx context.Context) (bool, string, error) {
+		state, err := ec2.GetInstanceState(ec2api, ctx, instanceId)
+		if err != nil {
+			return false, "", err
+		}
+		return state == expected, string(state), nil
+	})
+}
+
+// stopInstanceOnExit stops the instance when it is started by ec2rdp and --stop-on-exit flag is specified.
+func stopInstanceOnExit(ec2api ec2.EC2API, instanceId string, started bool) {
+	if !started || !cpStopOnExit {
+		return
+	}
+	// the command context may be canceled already
+	fmt.Printf("Stopping instance %v\n", instanceId)
+	err := ec2.StopInstance(ec2api, context.Background(), instanceId)
+	if err != nil {
+		fmt.Printf("Failed to stop instance %v (%v)\n", instanceId, err)
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	awsssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/wait"
+)
+
+// stateMockEC2API returns the instance states in order, and records start and stop calls.
+type stateMockEC2API struct {
+	MockEC2API
+	States  []ec2types.InstanceStateName
+	Started bool
+	Stopped bool
+}
+
+func (m *stateMockEC2API) DescribeInstances(ctx context.Context, params *awsec2.DescribeInstancesInput, optFns ...func(*awsec2.Options)) (*awsec2.DescribeInstancesOutput, error) {
+	state := m.States[0]
+	if len(m.States) > 1 {
+		m.States = m.States[1:]
+	}
+	return &awsec2.DescribeInstancesOutput{
+		Reservations: []ec2types.Reservation{{Instances: []ec2types.Instance{{InstanceId: aws.String(params.InstanceIds[0]), State: &ec2types.InstanceState{Name: state}}}}},
+	}, nil
+}
+
+func (m *stateMockEC2API) StartInstances(ctx context.Context, params *awsec2.StartInstancesInput, optFns ...func(*awsec2.Options)) (*awsec2.StartInstancesOutput, error) {
+	m.Started = true
+	return &awsec2.StartInstancesOutput{}, nil
+}
+
+func (m *stateMockEC2API) StopInstances(ctx context.Context, params *awsec2.StopInstancesInput, optFns ...func(*awsec2.Options)) (*awsec2.StopInstancesOutput, error) {
+	m.Stopped = true
+	return &awsec2.StopInstancesOutput{}, nil
+}
+
+func Test_ensureInstanceRunning(t *testing.T) {
+	defer func(start, stop bool, f func() *wait.Poller) {
+		cpStartInstance, cpStopOnExit, newStartPoller = start, stop, f
+	}(cpStartInstance, cpStopOnExit, newStartPoller)
+	newStartPoller = func() *wait.Poller {
+		return &wait.Poller{Interval: time.Millisecond, Timeout: time.Second}
+	}
+	instanceId := "i-01234567890abcdef"
+	ssmmock := &MockSSMAPI{
+		DescribeInstanceInformationOutput: &awsssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []ssmtypes.InstanceInformation{{InstanceId: aws.String(instanceId), PingStatus: ssmtypes.PingStatusOnline}},
+		},
+	}
+	ready := readiness{PasswordData: true, SSMOnline: true}
+
+	// running
+	cpStartInstance = false
+	mock := &stateMockEC2API{States: []ec2types.InstanceStateName{ec2types.InstanceStateNameRunning}}
+	started, err := ensureInstanceRunning(mock, ssmmock, context.Background(), instanceId, ready)
+	if started || err != nil || mock.Started {
+		t.Errorf("Running instance must not be started (%v, %v)", started, err)
+	}
+
+	// stopped without --start flag
+	mock = &stateMockEC2API{States: []ec2types.InstanceStateName{ec2types.InstanceStateNameStopped}}
+	_, err = ensureInstanceRunning(mock, ssmmock, context.Background(), instanceId, ready)
+	if err == nil || !strings.Contains(err.Error(), "--start") || mock.Started {
+		t.Errorf("Stopped instance must not be started without --start flag (%v)", err)
+	}
+
+	// stopped with --start flag
+	cpStartInstance = true
+	mock = &stateMockEC2API{States: []ec2types.InstanceStateName{ec2types.InstanceStateNameStopped, ec2types.InstanceStateNamePending, ec2types.InstanceStateNameRunning}}
+	mock.DescribeInstanceStatusOutput = &awsec2.DescribeInstanceStatusOutput{
+		InstanceStatuses: []ec2types.InstanceStatus{{
+			InstanceStatus: &ec2types.InstanceStatusSummary{Status: ec2types.SummaryStatusOk},
+			SystemStatus:   &ec2types.InstanceStatusSummary{Status: ec2types.SummaryStatusOk},
+		}},
+	}
+	mock.GetPasswordDataOutput = &awsec2.GetPasswordDataOutput{PasswordData: aws.String("data")}
+	started, err = ensureInstanceRunning(mock, ssmmock, context.Background(), instanceId, ready)
+	if !started || err != nil || !mock.Started {
+		t.Errorf("Failed to start instance (%v, %v)", started, err)
+	}
+
+	// SSM Agent of the instance not managed by SSM is not waited
+	mock.States = []ec2types.InstanceStateName{ec2types.InstanceStateNameStopped, ec2types.InstanceStateNameRunning}
+	started, err = ensureInstanceRunning(mock, &MockSSMAPI{DescribeInstanceInformationOutput: &awsssm.DescribeInstanceInformationOutput{}}, context.Background(), instanceId, ready)
+	if !started || err != nil {
+		t.Errorf("Instance not managed by SSM must not wait for SSM Agent (%v, %v)", started, err)
+	}
+
+	// stop on exit
+	cpStopOnExit = false
+	stopInstanceOnExit(mock, instanceId, true)
+	if mock.Stopped {
+		t.Error("Instance must not be stopped without --stop-on-exit flag")
+	}
+	cpStopOnExit = true
+	stopInstanceOnExit(mock, instanceId, false)
+	if mock.Stopped {
+		t.Error("Instance not started by ec2rdp must not be stopped")
+	}
+	stopInstanceOnExit(mock, instanceId, true)
+	if !mock.Stopped {
+		t.Error("Failed to stop instance")
+	}
+
+	// terminated
+	mock = &stateMockEC2API{States: []ec2types.InstanceStateName{ec2types.InstanceStateNameTerminated}}
+	if _, err = ensureInstanceRunning(mock, ssmmock, context.Background(), instanceId, ready); err == nil || mock.Started {
+		t.Errorf("Terminated instance must not be started (%v)", err)
+	}
+}
+
+func Test_stopOnExitFlags(t *testing.T) {
+	defer func(start, stop, nowait bool) {
+		cpStartInstance, cpStopOnExit, publicNoWait = start, stop, nowait
+	}(cpStartInstance, cpStopOnExit, publicNoWait)
+
+	// --nowait flag can not be used with --stop-on-exit flag
+	c := &cobra.Command{Use: "public"}
+	addPublicFlags(c)
+	addPublicNoWaitFlags(c)
+	c.Flags().Set("start", "true")
+	c.Flags().Set("stop-on-exit", "true")
+	if err := c.ValidateFlagGroups(); err != nil {
+		t.Errorf("--stop-on-exit flag must be valid without --nowait flag (%v)", err)
+	}
+	c.Flags().Set("nowait", "true")
+	if err := c.ValidateFlagGroups(); err == nil {
+		t.Error("--nowait flag must not be used with --stop-on-exit flag")
+	}
+
+	// rdpfile public command exits before the .rdp file is used
+	if err := rdpfilePublicCmd.Args(rdpfilePublicCmd, []string{}); err == nil || !strings.Contains(err.Error(), "--stop-on-exit") {
+		t.Errorf("--stop-on-exit flag must not be used with rdpfile public command (%v)", err)
+	}
+}
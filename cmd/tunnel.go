@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2instanceconnect"
+	"github.com/stknohg/ec2rdp/internal/aws/ssm"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
+)
+
+var (
+	tunnelForwards []string
+	tunnelJSON     bool
+)
+
+type forwardSpec struct {
+	RemotePort int
+	LocalPort  int // 0 means the first free port from RemotePort
+}
+
+type tunnelResult struct {
+	InstanceId string          `json:"instanceId"`
+	Mode       string          `json:"mode"`
+	Forwards   []forwardResult `json:"forwards"`
+}
+
+type forwardResult struct {
+	LocalHost  string `json:"localHost"`
+	LocalPort  int    `json:"localPort"`
+	RemotePort int    `json:"remotePort"`
+	SessionId  string `json:"sessionId,omitempty"`
+}
+
+// tunnelCmd represents the tunnel command
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "Open tunnels to EC2 instance without launching RDP client",
+	Long: `Open tunnels to EC2 instance without launching RDP client.
+Tunnels are kept open until you press Ctrl-C.`,
+}
+
+// tunnelSSMCmd represents the tunnel ssm command
+var tunnelSSMCmd = &cobra.Command{
+	Use:   "ssm",
+	Short: "Open tunnels via SSM Session Manager",
+	Long:  `Open tunnels via SSM Session Manager`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := validateInstanceFlags(); err != nil {
+			return err
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		if err := validateEndpointURLFlags(); err != nil {
+			return err
+		}
+		_, err := parseForwards(tunnelForwards)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return invokeTunnelSSMCommand(cmd, args)
+	},
+}
+
+// tunnelEICECmd represents the tunnel eice command
+var tunnelEICECmd = &cobra.Command{
+	Use:   "eice",
+	Short: "Open tunnels via EC2 Instance Connect Endpoint",
+	Long:  `Open tunnels via EC2 Instance Connect Endpoint`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := validateInstanceFlags(); err != nil {
+			return err
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		if err := validateEndpointURLFlags(); err != nil {
+			return err
+		}
+		_, err := parseForwards(tunnelForwards)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return invokeTunnelEICECommand(cmd, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tunnelCmd)
+	tunnelCmd.AddCommand(tunnelSSMCmd)
+	tunnelCmd.AddCommand(tunnelEICECmd)
+	addTunnelFlags(tunnelSSMCmd)
+	addTunnelFlags(tunnelEICECmd)
+	tunnelEICECmd.Flags().StringVarP(&eiceEndpointId, "endpointid", "e", "", "EC2 Instance Connect Endpoint ID")
+	tunnelEICECmd.RegisterFlagCompletionFunc("endpointid", invokeEndpointCompletion)
+}
+
+func addTunnelFlags(c *cobra.Command) {
+	addInstanceFlags(c)
+	c.Flags().StringArrayVar(&tunnelForwards, "forward", []string{"3389"}, "Forward REMOTE[:LOCAL] port. Can be specified multiple times")
+	c.Flags().BoolVar(&tunnelJSON, "json", false, "Print local endpoints as JSON")
+	c.Flags().StringVar(&cpProfileName, "profile", "", "AWS profile name")
+	c.Flags().StringVar(&cpRegionName, "region", "", "AWS region name")
+	addAssumeRoleFlags(c)
+	addEndpointURLFlags(c)
+	c.Flags().IntVar(&cpReconnectAttempts, "reconnect", 5, "Max reconnect attempts when the tunnel is disconnected (0 to disable)")
+	//
+	// custom completion
+	c.RegisterFlagCompletionFunc("region", invokeRegionCompletion)
+	c.RegisterFlagCompletionFunc("profile", invokeProfileCompletion)
+}
+
+func parseForwards(values []string) ([]forwardSpec, error) {
+	if len(values) == 0 {
+		return nil, errors.New("--forward flag is required")
+	}
+	forwards := make([]forwardSpec, 0, len(values))
+	for _, v := range values {
+		f, err := parseForward(v)
+		if err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, f)
+	}
+	return forwards, nil
+}
+
+func parseForward(value string) (forwardSpec, error) {
+	remote, local, found := strings.Cut(value, ":")
+	remotePort, err := strconv.Atoi(remote)
+	if err != nil {
+		return forwardSpec{}, fmt.Errorf("invalid forward %v (expected REMOTE[:LOCAL])", value)
+	}
+	err = validatePort(remotePort)
+	if err != nil {
+		return forwardSpec{}, err
+	}
+	if !found {
+		return forwardSpec{RemotePort: remotePort}, nil
+	}
+	localPort, err := strconv.Atoi(local)
+	if err != nil {
+		return forwardSpec{}, fmt.Errorf("invalid forward %v (expected REMOTE[:LOCAL])", value)
+	}
+	err = validatePort(localPort)
+	if err != nil {
+		return forwardSpec{}, err
+	}
+	return forwardSpec{RemotePort: remotePort, LocalPort: localPort}, nil
+}
+
+func invokeTunnelSSMCommand(_ *cobra.Command, _ []string) error {
+	forwards, err := parseForwards(tunnelForwards)
+	if err != nil {
+		return err
+	}
+
+	// get aws config
+	cfg, err := getAWSConfig(tunnelPrintf)
+	if err != nil {
+		return err
+	}
+	ec2api := ec2.NewAPI(cfg)
+	ssmapi := ssm.NewAPI(cfg)
+	ctx, stop := newSignalContext(context.Background())
+	defer stop()
+
+	// validate region
+	err = validateRegion(ec2api, ctx, tunnelPrintf)
+	if err != nil {
+		return err
+	}
+
+	return runTunnelSSM(ctx, ec2api, ssmapi, forwards, func(remotePort int, localPort int) tunnel.Tunnel {
+		return withStreamURL(cfg, ssm.NewSSMSessionPortForward(ssmapi, cpInstanceId, remotePort, localPort, "ec2rdp tunnel ssm"))
+	})
+}
+
+// runTunnelSSM resolves the instance and opens the tunnels via SSM Session Manager.
+func runTunnelSSM(ctx context.Context, ec2api ec2.EC2API, ssmapi ssm.SSMAPI, forwards []forwardSpec, newTunnel func(remotePort int, localPort int) tunnel.Tunnel) error {
+	// resolve instance
+	err := resolveInstanceId(ec2api, ssmapi, ctx, tunnelPrintf)
+	if err != nil {
+		return err
+	}
+
+	// check instance exists
+	_, err = ec2.IsInstanceExist(ec2api, ctx, cpInstanceId)
+	if err != nil {
+		return err
+	}
+
+	// check instance status
+	_, err = ssm.IsInstanceOnline(ssmapi, ctx, cpInstanceId)
+	if err != nil {
+		return err
+	}
+
+	return startTunnels(ctx, "ssm", forwards, newTunnel)
+}
+
+func invokeTunnelEICECommand(_ *cobra.Command, _ []string) error {
+	forwards, err := parseForwards(tunnelForwards)
+	if err != nil {
+		return err
+	}
+
+	// get aws config
+	cfg, err := getAWSConfig(tunnelPrintf)
+	if err != nil {
+		return err
+	}
+	ec2api := ec2.NewAPI(cfg)
+	ssmapi := ssm.NewAPI(cfg)
+	ctx, stop := newSignalContext(context.Background())
+	defer stop()
+
+	// validate region
+	err = validateRegion(ec2api, ctx, tunnelPrintf)
+	if err != nil {
+		return err
+	}
+
+	// resolve instance
+	err = resolveInstanceId(ec2api, ssmapi, ctx, tunnelPrintf)
+	if err != nil {
+		return err
+	}
+
+	// check instance exists
+	_, err = ec2.IsInstanceExist(ec2api, ctx, cpInstanceId)
+	if err != nil {
+		return err
+	}
+
+	// get instance metadata and EC2 Insntance Connect Endpoint information
+	metadata, fetchResult, err := getEICETarget(ec2api, ctx, cpInstanceId, eiceEndpointId)
+	if err != nil {
+		return err
+	}
+	if eiceEndpointId == "" {
+		tunnelPrintf("Find EC2 Instance Connect Endpoint %v in the VPC\n", fetchResult.EndpointId)
+	}
+
+	return startTunnels(ctx, "eice", forwards, func(remotePort int, localPort int) tunnel.Tunnel {
+		return ec2instanceconnect.NewTunnel(cfg, fetchResult.EndpointId, fetchResult.DnsName, metadata.PrivateIpAddress, localPort, remotePort)
+	})
+}
+
+// startTunnels opens all forwards and keeps them open until the context is canceled or any tunnel is closed.
+func startTunnels(ctx context.Context, mode string, forwards []forwardSpec, newTunnel func(remotePort int, localPort int) tunnel.Tunnel) error {
+	var localHostName = "localhost"
+	result := tunnelResult{InstanceId: cpInstanceId, Mode: mode, Forwards: []forwardResult{}}
+	opened := []tunnel.Tunnel{}
+	defer func() {
+		for _, t := range opened {
+			t.Close()
+		}
+		if len(opened) > 0 {
+			tunnelPrintf("Close %v tunnel(s)\n", len(opened))
+		}
+	}()
+
+	for _, f := range forwards {
+		localPort := f.LocalPort
+		if localPort == 0 {
+			port, err := getLocalRDPPort(localHostName, f.RemotePort)
+			if err != nil {
+				return err
+			}
+			localPort = port
+		}
+		t := newReconnectingTunnel(func() tunnel.Tunnel {
+			return newTunnel(f.RemotePort, localPort)
+		})
+		err := openTunnel(ctx, t)
+		if err != nil {
+			return fmt.Errorf("failed to forward port %v: %w", f.RemotePort, err)
+		}
+		opened = append(opened, t)
+		fr := forwardResult{LocalHost: localHostName, LocalPort: localPort, RemotePort: f.RemotePort}
+		current := t
+		if r, ok := t.(*tunnel.Reconnector); ok {
+			current = r.Current()
+		}
+		if session, ok := current.(*ssm.PortForwardingSession); ok {
+			fr.SessionId = session.SessionId
+		}
+		result.Forwards = append(result.Forwards, fr)
+		if !tunnelJSON {
+			fmt.Printf("Forward %v:%v to %v:%v\n", localHostName, localPort, cpInstanceId, f.RemotePort)
+		}
+	}
+	if tunnelJSON {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+	}
+	tunnelPrintf("Press Ctrl-C to close tunnels\n")
+
+	// wait for Ctrl-C or closing any tunnel
+	closed := make(chan int, len(opened))
+	for i, t := range opened {
+		go func() {
+			select {
+			case <-t.Done():
+				closed <- i
+			case <-ctx.Done():
+			}
+		}()
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case i := <-closed:
+		return fmt.Errorf("tunnel to port %v is closed unexpectedly", result.Forwards[i].RemotePort)
+	}
+}
+
+// tunnelPrintf prints messages to stderr in JSON mode to keep stdout parsable.
+func tunnelPrintf(format string, a ...any) {
+	if tunnelJSON {
+		fmt.Fprintf(os.Stderr, format, a...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
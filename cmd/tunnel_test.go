@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	awsssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
+)
+
+func Test_parseForward(t *testing.T) {
+	// remote only
+	f, err := parseForward("3389")
+	if err != nil || f.RemotePort != 3389 || f.LocalPort != 0 {
+		t.Errorf("Failed to parse remote port (%+v, %v)", f, err)
+	}
+	// remote and local
+	f, err = parseForward("5985:15985")
+	if err != nil || f.RemotePort != 5985 || f.LocalPort != 15985 {
+		t.Errorf("Failed to parse remote and local port (%+v, %v)", f, err)
+	}
+	// invalid values
+	for _, v := range []string{"", "abc", "0", "65536", "3389:", "3389:abc", "3389:0", ":3389"} {
+		if _, err := parseForward(v); err == nil {
+			t.Errorf("Failed to detect invalid forward %q", v)
+		}
+	}
+	// empty
+	if _, err := parseForwards([]string{}); err == nil {
+		t.Error("Failed to detect empty forwards")
+	}
+}
+
+type fakeTunnel struct {
+	tunnel.State
+	RemotePort int
+	LocalPort  int
+	Closed     bool
+}
+
+func (f *fakeTunnel) Open(ctx context.Context) error {
+	f.SetReady()
+	return nil
+}
+
+func (f *fakeTunnel) Close() error {
+	f.Closed = true
+	f.SetDone(nil)
+	return nil
+}
+
+func Test_startTunnels(t *testing.T) {
+	// disable reconnection
+	defer func(v int) { cpReconnectAttempts = v }(cpReconnectAttempts)
+	cpReconnectAttempts = 0
+
+	// all tunnels are closed after canceled
+	opened := []*fakeTunnel{}
+	newTunnel := func(remotePort int, localPort int) tunnel.Tunnel {
+		f := &fakeTunnel{RemotePort: remotePort, LocalPort: localPort}
+		opened = append(opened, f)
+		return f
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := startTunnels(ctx, "test", []forwardSpec{{RemotePort: 3389, LocalPort: 13389}, {RemotePort: 5985, LocalPort: 15985}}, newTunnel)
+	if err != nil {
+		t.Errorf("Canceled tunnels must not be error (%v)", err)
+	}
+	if len(opened) != 2 || opened[0].LocalPort != 13389 || opened[1].RemotePort != 5985 {
+		t.Errorf("Invalid forwards %+v", opened)
+	}
+	for _, f := range opened {
+		if !f.Closed {
+			t.Errorf("Tunnel to %v must be closed", f.RemotePort)
+		}
+	}
+
+	// unexpected closing is error
+	opened = []*fakeTunnel{}
+	newClosedTunnel := func(remotePort int, localPort int) tunnel.Tunnel {
+		f := newTunnel(remotePort, localPort).(*fakeTunnel)
+		f.SetDone(nil)
+		return f
+	}
+	err = startTunnels(context.Background(), "test", []forwardSpec{{RemotePort: 3389, LocalPort: 13389}}, newClosedTunnel)
+	if err == nil {
+		t.Error("Failed to detect closed tunnel")
+	}
+}
+
+func Test_runTunnelSSM_JSON(t *testing.T) {
+	defer func(id, name string, jsonOutput bool, attempts int) {
+		cpInstanceId, cpInstanceName, tunnelJSON, cpReconnectAttempts = id, name, jsonOutput, attempts
+	}(cpInstanceId, cpInstanceName, tunnelJSON, cpReconnectAttempts)
+	cpInstanceId, cpInstanceName, tunnelJSON, cpReconnectAttempts = "", "web01", true, 0
+
+	ec2mock := &MockEC2API{
+		DescribeInstancesOutput: &awsec2.DescribeInstancesOutput{
+			Reservations: []ec2types.Reservation{{Instances: []ec2types.Instance{{
+				InstanceId: aws.String("i-01234567890abcdef"),
+				State:      &ec2types.InstanceState{Name: ec2types.InstanceStateNameRunning},
+				Tags:       []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String("web01")}},
+			}}}},
+		},
+	}
+	ssmmock := &MockSSMAPI{
+		DescribeInstanceInformationOutput: &awsssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []ssmtypes.InstanceInformation{{InstanceId: aws.String("i-01234567890abcdef"), PingStatus: ssmtypes.PingStatusOnline}},
+		},
+	}
+	newTunnel := func(remotePort int, localPort int) tunnel.Tunnel {
+		return &fakeTunnel{RemotePort: remotePort, LocalPort: localPort}
+	}
+
+	// capture stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) { os.Stdout = f }(os.Stdout)
+	os.Stdout = w
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = runTunnelSSM(ctx, ec2mock, ssmmock, []forwardSpec{{RemotePort: 3389, LocalPort: 13389}}, newTunnel)
+	w.Close()
+	if err != nil {
+		t.Fatalf("Failed to run tunnel (%v)", err)
+	}
+
+	// stdout must have the JSON result only
+	var result tunnelResult
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&result); err != nil {
+		t.Fatalf("Failed to decode stdout (%v)", err)
+	}
+	if result.InstanceId != "i-01234567890abcdef" || result.Mode != "ssm" || len(result.Forwards) != 1 || result.Forwards[0].LocalPort != 13389 {
+		t.Errorf("Invalid result %+v", result)
+	}
+	if decoder.More() {
+		t.Error("Stdout must not have other output")
+	}
+}
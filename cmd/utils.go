@@ -2,22 +2,62 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/connector"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
+	"github.com/stknohg/ec2rdp/internal/wait"
 	"golang.org/x/term"
 )
 
-func readPrompt(prompt string) string {
+const tunnelReadyTimeout = 30 * time.Second
+
+const (
+	passwordPollInterval    = 5 * time.Second
+	passwordPollMaxInterval = 30 * time.Second
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// validateRegion validates --region flag before calling other APIs.
+func validateRegion(ec2api ec2.EC2API, ctx context.Context, logf func(format string, a ...any)) error {
+	if cpRegionName == "" {
+		return nil
+	}
+	return newRegionProvider(ec2api, logf).Validate(ctx, cpRegionName)
+}
+
+// readPrompt reads the input without echo. It returns errInterrupted when the context is canceled.
+func readPrompt(ctx context.Context, prompt string) (string, error) {
 	fmt.Print(prompt)
-	val, _ := term.ReadPassword(int(syscall.Stdin))
-	fmt.Printf("\n")
-	return string(val)
+	fd := int(syscall.Stdin)
+	state, _ := term.GetState(fd)
+	done := make(chan string, 1)
+	go func() {
+		val, _ := term.ReadPassword(fd)
+		done <- string(val)
+	}()
+	select {
+	case val := <-done:
+		fmt.Printf("\n")
+		return val, nil
+	case <-ctx.Done():
+		// ReadPassword keeps the echo disabled until it returns.
+		if state != nil {
+			term.Restore(fd, state)
+		}
+		fmt.Printf("\n")
+		return "", errInterrupted
+	}
 }
 
 func isPortOpen(hostName string, port int) bool {
@@ -45,57 +85,160 @@ func getLocalRDPPort(localHost string, startPort int) (int, error) {
 	return 65535, fmt.Errorf("failed to find local proxy port")
 }
 
+// openTunnel opens the tunnel and waits until it gets ready.
+func openTunnel(ctx context.Context, t tunnel.Tunnel) error {
+	return tunnel.Start(ctx, t, tunnelReadyTimeout)
+}
+
+// newReconnectingTunnel returns the tunnel reopened on the same local port when it is disconnected.
+// Each attempt starts a new session, and the SDK refreshes expired credentials then.
+func newReconnectingTunnel(newTunnel func() tunnel.Tunnel) tunnel.Tunnel {
+	if cpReconnectAttempts <= 0 {
+		return newTunnel()
+	}
+	return &tunnel.Reconnector{
+		NewTunnel:    newTunnel,
+		MaxAttempts:  cpReconnectAttempts,
+		Interval:     time.Second,
+		ReadyTimeout: tunnelReadyTimeout,
+		Logf:         tunnelPrintf,
+	}
+}
+
+// newSignalContext returns the context canceled by Ctrl-C (SIGINT) or SIGTERM.
+// Call it before acquiring resources so that they are released by deferred functions instead of killing the process.
+func newSignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
+
+// runConnector invokes the RDP client.
+// PostConnect is always called after PreConnect succeeds, even if Connect fails or the context is canceled.
+func runConnector(ctx context.Context, con connector.Connector) error {
+	err := con.PreConnect()
+	if err != nil {
+		return err
+	}
+	defer con.PostConnect()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- con.Connect()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		fmt.Println()
+		return errInterrupted
+	}
+}
+
+// connectTunnelInstance connects to the local port of the tunnel and closes the tunnel on every exit path.
+func connectTunnelInstance(ctx context.Context, con connector.Connector, t tunnel.Tunnel) error {
+	defer func() {
+		fmt.Printf("Close %v\n", t)
+		t.Close()
+	}()
+	return runConnector(ctx, con)
+}
+
+func newConnector(clientName string, param *connector.DefaultConnector) (connector.Connector, error) {
+	display, err := getDisplayOptions()
+	if err != nil {
+		return nil, err
+	}
+	param.Display = display
+	if clientName == "" && cpClientCommand != "" {
+		clientName = "custom"
+	}
+	if clientName == "" {
+		// fallback to the first installed client
+		name, err := connector.Detect()
+		if err != nil {
+			return nil, err
+		}
+		clientName = name
+	}
+	err = connector.ValidateDisplay(clientName, display)
+	if err != nil {
+		return nil, err
+	}
+	con, err := connector.New(clientName, param)
+	if err != nil {
+		return nil, err
+	}
+	switch c := con.(type) {
+	case *connector.RDPFileConnector:
+		c.FilePath = rdpfileOutput
+		if c.FilePath == "" {
+			c.FilePath = cpInstanceId + ".rdp"
+		}
+		c.ShowPassword = rdpfileShowPassword
+	case *connector.CustomConnector:
+		c.Command = cpClientCommand
+		c.PasswordVia = cpClientPasswordVia
+	}
+	_, err = con.IsInstalled()
+	if err != nil {
+		return nil, err
+	}
+	return con, nil
+}
+
 func getAdministratorPasswordWithPrompt(ec2api ec2.EC2API, ctx context.Context, instanceId string, pemFile string, prompt bool) (string, string, error) {
 	if prompt {
-		password := readPrompt("Enter password:")
-		return password, "", nil
+		password, err := readPrompt(ctx, "Enter password:")
+		return password, "", err
+	}
+	if cpWaitPassword {
+		poller := &wait.Poller{Interval: passwordPollInterval, MaxInterval: passwordPollMaxInterval, Timeout: cpWaitPasswordTimeout, Logf: printProgress}
+		err := waitPasswordData(ec2api, poller, ctx, instanceId)
+		if err != nil {
+			return "", "", err
+		}
 	}
 	password, err := ec2.GetAdministratorPassword(ec2api, ctx, instanceId, pemFile)
 	if err != nil {
 		return "", "", err
 	}
 	if password == "" {
-		return "", "", fmt.Errorf("EC2 PasswordData is empty. Use --password flag instead")
+		return "", "", passwordDataError(ec2.CheckPasswordData(ec2api, ctx, instanceId, 0))
 	}
 	return password, "Administrator password acquisition completed", nil
 }
 
-func invokeRegionCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	// generate from : aws ec2 describe-regions --all-regions --query "sort_by(Regions,&RegionName)[].RegionName" --output json
-	regions := []string{
-		"af-south-1",
-		"ap-east-1",
-		"ap-east-2",
-		"ap-northeast-1",
-		"ap-northeast-2",
-		"ap-northeast-3",
-		"ap-south-1",
-		"ap-south-2",
-		"ap-southeast-1",
-		"ap-southeast-2",
-		"ap-southeast-3",
-		"ap-southeast-4",
-		"ap-southeast-5",
-		"ap-southeast-7",
-		"ca-central-1",
-		"ca-west-1",
-		"eu-central-1",
-		"eu-central-2",
-		"eu-north-1",
-		"eu-south-1",
-		"eu-south-2",
-		"eu-west-1",
-		"eu-west-2",
-		"eu-west-3",
-		"il-central-1",
-		"me-central-1",
-		"me-south-1",
-		"mx-central-1",
-		"sa-east-1",
-		"us-east-1",
-		"us-east-2",
-		"us-west-1",
-		"us-west-2",
-	}
-	return regions, cobra.ShellCompDirectiveDefault
+// waitPasswordData waits until EC2 PasswordData is generated. It fails soon when the password data will never be generated.
+func waitPasswordData(ec2api ec2.EC2API, poller *wait.Poller, ctx context.Context, instanceId string) error {
+	return poller.Until(ctx, "password data", func(ctx context.Context) (bool, string, error) {
+		// do not fail soon before the timeout specified by the user
+		err := ec2.CheckPasswordData(ec2api, ctx, instanceId, poller.Timeout)
+		if errors.Is(err, ec2.ErrPasswordDataNotGenerated) {
+			return false, "not generated yet", nil
+		}
+		if err != nil {
+			return false, "", passwordDataError(err)
+		}
+		return true, "", nil
+	})
+}
+
+// passwordDataError adds the hint to the password data error.
+func passwordDataError(err error) error {
+	switch {
+	case err == nil:
+		return errors.New("EC2 PasswordData is empty. Use --password flag instead")
+	case errors.Is(err, ec2.ErrPasswordDataNotGenerated):
+		return fmt.Errorf("%w. Wait a few minutes or use --wait-password flag", err)
+	case errors.Is(err, ec2.ErrPasswordDataNoKeyPair), errors.Is(err, ec2.ErrPasswordDataUnavailable):
+		return fmt.Errorf("%w. Use --password flag instead", err)
+	}
+	return err
+}
+
+func printProgress(format string, a ...any) {
+	fmt.Printf(format, a...)
+}
+
+func invokeClientCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return connector.Clients(), cobra.ShellCompDirectiveNoFileComp
 }
@@ -1,6 +1,20 @@
 package cmd
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stknohg/ec2rdp/internal/aws/ec2"
+	"github.com/stknohg/ec2rdp/internal/connector"
+	"github.com/stknohg/ec2rdp/internal/wait"
+)
 
 func Test_isPortOpen(t *testing.T) {
 	// Fail when invalid port is specified
@@ -15,3 +29,172 @@ func Test_isPortOpen(t *testing.T) {
 		t.Error("Must fail when non-existent hostname is specified")
 	}
 }
+
+type fakeConnector struct {
+	PreConnectError error
+	ConnectError    error
+	Block           chan struct{} // Connect blocks until closed
+	PreConnected    bool
+	PostConnected   bool
+}
+
+func (f *fakeConnector) IsInstalled() (bool, error) {
+	return true, nil
+}
+
+func (f *fakeConnector) PreConnect() error {
+	if f.PreConnectError != nil {
+		return f.PreConnectError
+	}
+	f.PreConnected = true
+	return nil
+}
+
+func (f *fakeConnector) Connect() error {
+	if f.Block != nil {
+		<-f.Block
+	}
+	return f.ConnectError
+}
+
+func (f *fakeConnector) PostConnect() error {
+	f.PostConnected = true
+	return nil
+}
+
+func Test_validateRegion(t *testing.T) {
+	// isolate the region cache
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("LocalAppData", dir)
+	defer func(v string) { cpRegionName = v }(cpRegionName)
+	cpRegionName = "ap-northeast-1"
+
+	// the fallback message is written to logf
+	messages := []string{}
+	logf := func(format string, a ...any) { messages = append(messages, fmt.Sprintf(format, a...)) }
+	err := validateRegion(&MockEC2API{Error: errors.New("access denied")}, context.Background(), logf)
+	if err != nil {
+		t.Errorf("Embedded region must be valid (%v)", err)
+	}
+	if len(messages) != 1 || !strings.Contains(messages[0], "access denied") {
+		t.Errorf("Invalid messages %q", messages)
+	}
+}
+
+func Test_runConnector(t *testing.T) {
+	// PostConnect is not called when PreConnect fails
+	con := &fakeConnector{PreConnectError: errors.New("pre connect error")}
+	if err := runConnector(context.Background(), con); err == nil {
+		t.Error("Failed to detect PreConnect error")
+	}
+	if con.PostConnected {
+		t.Error("PostConnect must not be called when PreConnect fails")
+	}
+
+	// PostConnect is called when Connect fails
+	con = &fakeConnector{ConnectError: errors.New("connect error")}
+	if err := runConnector(context.Background(), con); err == nil {
+		t.Error("Failed to detect Connect error")
+	}
+	if !con.PostConnected {
+		t.Error("PostConnect must be called when Connect fails")
+	}
+
+	// PostConnect is called when interrupted
+	con = &fakeConnector{Block: make(chan struct{})}
+	defer close(con.Block)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := runConnector(ctx, con); err != errInterrupted {
+		t.Errorf("Failed to detect interruption (%v)", err)
+	}
+	if !con.PostConnected {
+		t.Error("PostConnect must be called when interrupted")
+	}
+}
+
+func Test_connectTunnelInstance(t *testing.T) {
+	// tunnel is closed when PreConnect fails
+	con := &fakeConnector{PreConnectError: errors.New("pre connect error")}
+	tun := &fakeTunnel{}
+	if err := connectTunnelInstance(context.Background(), con, tun); err == nil {
+		t.Error("Failed to detect PreConnect error")
+	}
+	if !tun.Closed {
+		t.Error("Tunnel must be closed when PreConnect fails")
+	}
+
+	// tunnel is closed after the client exits
+	con = &fakeConnector{}
+	tun = &fakeTunnel{}
+	if err := connectTunnelInstance(context.Background(), con, tun); err != nil {
+		t.Errorf("Failed to connect (%v)", err)
+	}
+	if !tun.Closed || !con.PostConnected {
+		t.Error("Tunnel and connector must be cleaned up")
+	}
+
+	// tunnel is closed when interrupted
+	con = &fakeConnector{Block: make(chan struct{})}
+	defer close(con.Block)
+	tun = &fakeTunnel{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := connectTunnelInstance(ctx, con, tun); err != errInterrupted {
+		t.Errorf("Failed to detect interruption (%v)", err)
+	}
+	if !tun.Closed || !con.PostConnected {
+		t.Error("Tunnel and connector must be cleaned up when interrupted")
+	}
+}
+
+func Test_waitPasswordData(t *testing.T) {
+	instanceId := "i-01234567890abcdef"
+	newMock := func(passwordData string, keyName *string) *MockEC2API {
+		launchTime := time.Now()
+		return &MockEC2API{
+			GetPasswordDataOutput: &awsec2.GetPasswordDataOutput{PasswordData: aws.String(passwordData)},
+			DescribeInstancesOutput: &awsec2.DescribeInstancesOutput{
+				Reservations: []ec2types.Reservation{{Instances: []ec2types.Instance{{InstanceId: aws.String(instanceId), KeyName: keyName, LaunchTime: &launchTime}}}},
+			},
+		}
+	}
+	poller := &wait.Poller{Interval: time.Millisecond, Timeout: 20 * time.Millisecond}
+
+	// available
+	if err := waitPasswordData(newMock("data", aws.String("key")), poller, context.Background(), instanceId); err != nil {
+		t.Errorf("PasswordData is available (%v)", err)
+	}
+	// not generated until timeout
+	err := waitPasswordData(newMock("", aws.String("key")), poller, context.Background(), instanceId)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Failed to detect timeout (%v)", err)
+	}
+	// never generated
+	err = waitPasswordData(newMock("", nil), poller, context.Background(), instanceId)
+	if !errors.Is(err, ec2.ErrPasswordDataNoKeyPair) || !strings.Contains(err.Error(), "--password") {
+		t.Errorf("Failed to detect no key pair (%v)", err)
+	}
+	// hint to wait
+	err = passwordDataError(ec2.ErrPasswordDataNotGenerated)
+	if !strings.Contains(err.Error(), "--wait-password") {
+		t.Errorf("Invalid hint (%v)", err)
+	}
+}
+
+func Test_publicWaitFor(t *testing.T) {
+	defer func(nowait bool) { publicNoWait = nowait }(publicNoWait)
+	publicNoWait = false
+	if publicWaitFor(&connector.RDPFileConnector{DefaultConnector: &connector.DefaultConnector{}}) {
+		t.Error(".rdp file of public instance must not wait for Enter")
+	}
+	if !publicWaitFor(&connector.CustomConnector{DefaultConnector: &connector.DefaultConnector{}}) {
+		t.Error("Client must be waited without --nowait flag")
+	}
+	publicNoWait = true
+	if publicWaitFor(&connector.CustomConnector{DefaultConnector: &connector.DefaultConnector{}}) {
+		t.Error("Client must not be waited with --nowait flag")
+	}
+}
@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	awssts "github.com/stknohg/ec2rdp/internal/aws/sts"
+)
+
+// AssumeRoleOptions is the parameters to assume roles on the loaded config.
+type AssumeRoleOptions struct {
+	RoleArns        []string // assumed in order (role chaining)
+	ExternalId      string   // used for the last role
+	RoleSessionName string
+	MFASerial       string // used for the first role
+	Duration        time.Duration
+	TokenProvider   func(serialNumber string) (string, error) // MFA token provider, PromptMFAToken by default
+	Cache           *RoleCache                                // cache of assumed role credentials, nil to disable
+}
+
+// AssumeRole returns the config whose credentials are the assumed role credentials.
+// Each role is assumed with the credentials of the previous role, so the roles can be chained.
+// The credentials are cached, and the MFA code is prompted only when the cache is expired.
+func AssumeRole(cfg aws.Config, options AssumeRoleOptions) aws.Config {
+	return assumeRole(cfg, options, func(cfg aws.Config) stscreds.AssumeRoleAPIClient {
+		return awssts.NewClient(cfg)
+	})
+}
+
+func assumeRole(cfg aws.Config, options AssumeRoleOptions, newClient func(cfg aws.Config) stscreds.AssumeRoleAPIClient) aws.Config {
+	tokenProvider := options.TokenProvider
+	if tokenProvider == nil {
+		tokenProvider = PromptMFAToken
+	}
+	sessionName := options.RoleSessionName
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("ec2rdp-%v", time.Now().Unix())
+	}
+	for i, roleArn := range options.RoleArns {
+		first := i == 0
+		last := i == len(options.RoleArns)-1
+		provider := stscreds.NewAssumeRoleProvider(newClient(cfg), roleArn, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+			if options.Duration > 0 {
+				o.Duration = options.Duration
+			}
+			if last && options.ExternalId != "" {
+				o.ExternalID = aws.String(options.ExternalId)
+			}
+			if first && options.MFASerial != "" {
+				o.SerialNumber = aws.String(options.MFASerial)
+			}
+			withRoleCache(o, options.Cache, tokenProvider)
+		})
+		next := cfg.Copy()
+		next.Credentials = aws.NewCredentialsCache(provider)
+		cfg = next
+	}
+	return cfg
+}
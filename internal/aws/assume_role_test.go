@@ -0,0 +1,71 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// MockSTSAPI records AssumeRole calls with the caller's access key.
+type MockSTSAPI struct {
+	CallerAccessKeyId string
+	Calls             *[]*sts.AssumeRoleInput
+	Callers           *[]string
+}
+
+func (m *MockSTSAPI) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	*m.Calls = append(*m.Calls, params)
+	*m.Callers = append(*m.Callers, m.CallerAccessKeyId)
+	return &sts.AssumeRoleOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("ASIA" + *params.RoleArn),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func Test_AssumeRole(t *testing.T) {
+	calls := []*sts.AssumeRoleInput{}
+	callers := []string{}
+	cfg := aws.Config{Region: "ap-northeast-1", Credentials: credentials.NewStaticCredentialsProvider("AKIABASE", "secret", "")}
+	options := AssumeRoleOptions{
+		RoleArns:        []string{"role1", "role2"},
+		ExternalId:      "external",
+		RoleSessionName: "session",
+		MFASerial:       "mfa",
+		Duration:        30 * time.Minute,
+		TokenProvider:   func(serialNumber string) (string, error) { return "123456", nil },
+	}
+	result := assumeRole(cfg, options, func(cfg aws.Config) stscreds.AssumeRoleAPIClient {
+		creds, _ := cfg.Credentials.Retrieve(context.Background())
+		return &MockSTSAPI{CallerAccessKeyId: creds.AccessKeyID, Calls: &calls, Callers: &callers}
+	})
+	creds, err := result.Credentials.Retrieve(context.Background())
+	if err != nil || creds.AccessKeyID != "ASIArole2" {
+		t.Fatalf("Invalid credentials %v (%v)", creds.AccessKeyID, err)
+	}
+	if len(calls) != 2 || callers[0] != "AKIABASE" || callers[1] != "ASIArole1" {
+		t.Fatalf("Roles must be chained (%v)", callers)
+	}
+	first, last := calls[0], calls[1]
+	if aws.ToString(first.SerialNumber) != "mfa" || aws.ToString(first.TokenCode) != "123456" || first.ExternalId != nil {
+		t.Errorf("MFA must be used for the first role %+v", first)
+	}
+	if aws.ToString(last.ExternalId) != "external" || last.SerialNumber != nil {
+		t.Errorf("External ID must be used for the last role %+v", last)
+	}
+	if aws.ToString(last.RoleSessionName) != "session" || aws.ToInt32(last.DurationSeconds) != 1800 {
+		t.Errorf("Invalid session name or duration %+v", last)
+	}
+	if result.Region != "ap-northeast-1" {
+		t.Errorf("Region must be kept (%v)", result.Region)
+	}
+}
@@ -8,9 +8,48 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/stknohg/ec2rdp/internal/aws/sso"
 )
 
-func GetConfig(profileName string, regionName string) aws.Config {
+// GetConfig loads the AWS configuration for the commands.
+// It logs in to IAM Identity Center if needed, and returns the actionable error for the common failures.
+func GetConfig(profileName string, regionName string) (aws.Config, error) {
+	// login to IAM Identity Center before loading the config, instead of aws sso login command
+	err := sso.EnsureToken(context.Background(), profileName, os.Stderr)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	cfg, err := LoadConfig(profileName, regionName)
+	if err != nil {
+		return aws.Config{}, DescribeError(profileName, err)
+	}
+	if cfg.Region == "" {
+		return aws.Config{}, fmt.Errorf("%w\nUse --region flag or AWS_REGION environment variable, or set region of the profile", ErrNoRegion)
+	}
+	return cfg, nil
+}
+
+// LoadOption changes the behavior of LoadConfig.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	tokenProvider func(serialNumber string) (string, error)
+}
+
+// WithMFATokenProvider replaces the MFA token provider (PromptMFAToken by default) of the role in the profile.
+func WithMFATokenProvider(tokenProvider func(serialNumber string) (string, error)) LoadOption {
+	return func(o *loadOptions) {
+		o.tokenProvider = tokenProvider
+	}
+}
+
+// LoadConfig loads the AWS configuration, and returns the error instead of panic.
+func LoadConfig(profileName string, regionName string, options ...LoadOption) (aws.Config, error) {
+	loadOpts := loadOptions{tokenProvider: PromptMFAToken}
+	for _, o := range options {
+		o(&loadOpts)
+	}
+
 	// ref : https://aws.github.io/aws-sdk-go-v2/docs/configuring-sdk/
 	//       https://zenn.dev/kz23szk/articles/f3e8fc167fdeeb
 	var optFunctions = make([]func(*config.LoadOptions) error, 0)
@@ -26,15 +65,10 @@ func GetConfig(profileName string, regionName string) aws.Config {
 	if profileName != "" {
 		optFunctions = append(optFunctions, config.WithSharedConfigProfile(profileName))
 	}
+	// cache the assumed role credentials of the profile in the same way as AWS CLI
 	optFunctions = append(optFunctions, config.WithAssumeRoleCredentialOptions(func(options *stscreds.AssumeRoleOptions) {
-		options.TokenProvider = func() (string, error) {
-			return stscreds.StdinTokenProvider()
-		}
+		withRoleCache(options, DefaultRoleCache(), loadOpts.tokenProvider)
 	}))
 
-	cfg, err := config.LoadDefaultConfig(context.Background(), optFunctions...)
-	if err != nil {
-		panic(fmt.Sprintf("aws configuration error, %v", err.Error()))
-	}
-	return cfg
+	return config.LoadDefaultConfig(context.Background(), optFunctions...)
 }
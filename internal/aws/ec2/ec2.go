@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/smithy-go"
+	"github.com/stknohg/ec2rdp/internal/aws/endpoint"
 )
 
 type EC2API interface {
@@ -22,6 +23,14 @@ type EC2API interface {
 	DescribeInstanceConnectEndpoints(ctx context.Context, params *ec2.DescribeInstanceConnectEndpointsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceConnectEndpointsOutput, error)
 
 	GetPasswordData(ctx context.Context, params *ec2.GetPasswordDataInput, optFns ...func(*ec2.Options)) (*ec2.GetPasswordDataOutput, error)
+
+	DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
+
+	DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error)
+
+	StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error)
+
+	StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
 }
 
 type InstanceMetadataForEICE struct {
@@ -37,7 +46,11 @@ type EICEndpointMetadata struct {
 }
 
 func NewAPI(cfg aws.Config) EC2API {
-	return ec2.NewFromConfig(cfg)
+	return ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if u, ok := endpoint.Resolve(cfg, endpoint.EC2); ok {
+			o.BaseEndpoint = aws.String(u)
+		}
+	})
 }
 
 func IsInstanceExist(api EC2API, ctx context.Context, instanceId string) (bool, error) {
@@ -95,6 +108,22 @@ func GetInstanceMetadataForEICE(api EC2API, ctx context.Context, instanceId stri
 	}, nil
 }
 
+func GetPrivateIpAddress(api EC2API, ctx context.Context, instanceId string) (string, error) {
+	input := &ec2.DescribeInstancesInput{InstanceIds: []string{instanceId}}
+	output, err := api.DescribeInstances(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range output.Reservations {
+		for _, i := range r.Instances {
+			if i.PrivateIpAddress != nil && *i.PrivateIpAddress != "" {
+				return *i.PrivateIpAddress, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("failed to find private IP address of instance %v", instanceId)
+}
+
 func fetchEICEndpoint(api EC2API, ctx context.Context, input *ec2.DescribeInstanceConnectEndpointsInput) (*EICEndpointMetadata, error) {
 	output, err := api.DescribeInstanceConnectEndpoints(ctx, input)
 	if err != nil {
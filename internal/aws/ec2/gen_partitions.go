@@ -0,0 +1,76 @@
+//go:build ignore
+
+// This program generates partitions_gen.go from partitions.json in aws-sdk-go-v2.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type partitionsFile struct {
+	Partitions []struct {
+		Id      string `json:"id"`
+		Outputs struct {
+			ImplicitGlobalRegion string `json:"implicitGlobalRegion"`
+		} `json:"outputs"`
+		RegionRegex string                     `json:"regionRegex"`
+		Regions     map[string]json.RawMessage `json:"regions"`
+	} `json:"partitions"`
+}
+
+func main() {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/aws/aws-sdk-go-v2").Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), "internal", "endpoints", "awsrulesfn", "partitions.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var file partitionsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "// Code generated by gen_partitions.go; DO NOT EDIT.")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "package ec2")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, `import "regexp"`)
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "var embeddedPartitions = []partition{")
+	for _, p := range file.Partitions {
+		regions := []string{}
+		for r := range p.Regions {
+			// skip pseudo regions like aws-global
+			if !strings.HasPrefix(r, p.Id+"-") {
+				regions = append(regions, r)
+			}
+		}
+		sort.Strings(regions)
+		fmt.Fprintf(buf, "{Id: %q, GlobalRegion: %q, RegionRegex: regexp.MustCompile(%q), Regions: []string{\n", p.Id, p.Outputs.ImplicitGlobalRegion, p.RegionRegex)
+		for _, r := range regions {
+			fmt.Fprintf(buf, "%q,\n", r)
+		}
+		fmt.Fprintln(buf, "}},")
+	}
+	fmt.Fprintln(buf, "}")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("partitions_gen.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,185 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var instanceIdPattern = regexp.MustCompile(`^i-[0-9a-f]{8}([0-9a-f]{9})?$`)
+
+// InstanceSummary is the instance information to identify the instance.
+type InstanceSummary struct {
+	InstanceId       string
+	Name             string
+	State            types.InstanceStateName
+	PrivateIpAddress string
+	PublicIpAddress  string
+	PrivateDnsName   string
+	PublicDnsName    string
+	VpcId            string
+	Platform         string
+}
+
+func (s InstanceSummary) String() string {
+	values := []string{}
+	for _, v := range []string{s.Name, string(s.State), s.PrivateIpAddress, s.PublicIpAddress} {
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return fmt.Sprintf("%v (%v)", s.InstanceId, strings.Join(values, ", "))
+}
+
+func IsInstanceId(value string) bool {
+	return instanceIdPattern.MatchString(value)
+}
+
+func NameFilter(name string) types.Filter {
+	return types.Filter{Name: aws.String("tag:Name"), Values: []string{name}}
+}
+
+func InstanceIdFilter(instanceId string) types.Filter {
+	return types.Filter{Name: aws.String("instance-id"), Values: []string{instanceId}}
+}
+
+func WindowsFilter() types.Filter {
+	return types.Filter{Name: aws.String("platform"), Values: []string{"windows"}}
+}
+
+// ParseFilters parses the filter expression like "tag:Env=prod,tag:Role=jump".
+func ParseFilters(expression string) ([]types.Filter, error) {
+	filters := []types.Filter{}
+	for _, f := range strings.Split(expression, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(f), "=")
+		if !found || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid filter %v (expected NAME=VALUE)", f)
+		}
+		filters = append(filters, types.Filter{Name: aws.String(name), Values: []string{value}})
+	}
+	return filters, nil
+}
+
+// FindInstances returns the instances matched with all filters.
+// Terminated instances are excluded unless instance-state-name filter is specified.
+func FindInstances(api EC2API, ctx context.Context, filters []types.Filter) ([]InstanceSummary, error) {
+	hasStateFilter := false
+	for _, f := range filters {
+		if aws.ToString(f.Name) == "instance-state-name" {
+			hasStateFilter = true
+		}
+	}
+	if !hasStateFilter {
+		filters = append(filters, types.Filter{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}})
+	}
+
+	summaries := []InstanceSummary{}
+	paginator := ec2.NewDescribeInstancesPaginator(api, &ec2.DescribeInstancesInput{Filters: filters})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range output.Reservations {
+			for _, i := range r.Instances {
+				summaries = append(summaries, newInstanceSummary(i))
+			}
+		}
+	}
+	return summaries, nil
+}
+
+// FindInstancesByAddress finds the instances by private or public IP address, or private or public DNS name.
+func FindInstancesByAddress(api EC2API, ctx context.Context, address string) ([]InstanceSummary, error) {
+	filterNames := []string{"private-dns-name", "dns-name"}
+	if net.ParseIP(address) != nil {
+		filterNames = []string{"private-ip-address", "ip-address"}
+	}
+	for _, name := range filterNames {
+		summaries, err := FindInstances(api, ctx, []types.Filter{{Name: aws.String(name), Values: []string{address}}})
+		if err != nil {
+			return nil, err
+		}
+		if len(summaries) > 0 {
+			return summaries, nil
+		}
+	}
+	return []InstanceSummary{}, nil
+}
+
+// EICEndpointSummary is the EC2 Instance Connect Endpoint information to identify the endpoint.
+type EICEndpointSummary struct {
+	EndpointId string
+	VpcId      string
+	Name       string
+}
+
+// ListEICEndpoints returns the available EC2 Instance Connect Endpoints.
+func ListEICEndpoints(api EC2API, ctx context.Context) ([]EICEndpointSummary, error) {
+	input := &ec2.DescribeInstanceConnectEndpointsInput{
+		Filters: []types.Filter{{Name: aws.String("state"), Values: []string{"create-complete"}}},
+	}
+	endpoints := []EICEndpointSummary{}
+	paginator := ec2.NewDescribeInstanceConnectEndpointsPaginator(api, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range output.InstanceConnectEndpoints {
+			endpoint := EICEndpointSummary{
+				EndpointId: aws.ToString(e.InstanceConnectEndpointId),
+				VpcId:      aws.ToString(e.VpcId),
+			}
+			for _, t := range e.Tags {
+				if aws.ToString(t.Key) == "Name" {
+					endpoint.Name = aws.ToString(t.Value)
+				}
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+// GetEICEndpointIdsByVpc returns the available EC2 Instance Connect Endpoint ID for each VPC.
+func GetEICEndpointIdsByVpc(api EC2API, ctx context.Context) (map[string]string, error) {
+	endpoints, err := ListEICEndpoints(api, ctx)
+	if err != nil {
+		return nil, err
+	}
+	endpointIds := map[string]string{}
+	for _, e := range endpoints {
+		if _, ok := endpointIds[e.VpcId]; !ok {
+			endpointIds[e.VpcId] = e.EndpointId
+		}
+	}
+	return endpointIds, nil
+}
+
+func newInstanceSummary(i types.Instance) InstanceSummary {
+	summary := InstanceSummary{
+		InstanceId:       aws.ToString(i.InstanceId),
+		PrivateIpAddress: aws.ToString(i.PrivateIpAddress),
+		PublicIpAddress:  aws.ToString(i.PublicIpAddress),
+		PrivateDnsName:   aws.ToString(i.PrivateDnsName),
+		PublicDnsName:    aws.ToString(i.PublicDnsName),
+		VpcId:            aws.ToString(i.VpcId),
+		Platform:         aws.ToString(i.PlatformDetails),
+	}
+	if i.State != nil {
+		summary.State = i.State.Name
+	}
+	for _, t := range i.Tags {
+		if aws.ToString(t.Key) == "Name" {
+			summary.Name = aws.ToString(t.Value)
+		}
+	}
+	return summary
+}
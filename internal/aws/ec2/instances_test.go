@@ -0,0 +1,88 @@
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func Test_IsInstanceId(t *testing.T) {
+	for _, v := range []string{"i-01234567", "i-01234567890abcdef"} {
+		if !IsInstanceId(v) {
+			t.Errorf("%v is instance ID", v)
+		}
+	}
+	for _, v := range []string{"", "10.0.0.1", "i-0123", "i-01234567890ABCDEF", "ip-10-0-0-1.ec2.internal"} {
+		if IsInstanceId(v) {
+			t.Errorf("%v is not instance ID", v)
+		}
+	}
+}
+
+func Test_ParseFilters(t *testing.T) {
+	filters, err := ParseFilters("tag:Env=prod, tag:Role=jump")
+	if err != nil || len(filters) != 2 {
+		t.Fatalf("Failed to parse filters (%v, %v)", filters, err)
+	}
+	if aws.ToString(filters[1].Name) != "tag:Role" || filters[1].Values[0] != "jump" {
+		t.Errorf("Invalid filter %v=%v", aws.ToString(filters[1].Name), filters[1].Values)
+	}
+	for _, v := range []string{"", "tag:Env", "tag:Env=", "=prod", "tag:Env=prod,"} {
+		if _, err := ParseFilters(v); err == nil {
+			t.Errorf("Failed to detect invalid filter %q", v)
+		}
+	}
+}
+
+func Test_FindInstances(t *testing.T) {
+	var mock = &MockAPI{
+		DescribeInstancesOutput: &ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{
+				{Instances: []types.Instance{{
+					InstanceId:       aws.String("i-01234567890abcdef"),
+					State:            &types.InstanceState{Name: types.InstanceStateNameRunning},
+					PrivateIpAddress: aws.String("10.0.0.1"),
+					Tags:             []types.Tag{{Key: aws.String("Name"), Value: aws.String("web")}},
+				}}},
+				{Instances: []types.Instance{{InstanceId: aws.String("i-0fedcba9876543210")}}},
+			},
+		},
+	}
+	result, err := FindInstances(mock, context.Background(), []types.Filter{NameFilter("web")})
+	if err != nil || len(result) != 2 {
+		t.Fatalf("Failed to find instances (%v, %v)", result, err)
+	}
+	if result[0].Name != "web" || result[0].State != types.InstanceStateNameRunning {
+		t.Errorf("Invalid instance summary %+v", result[0])
+	}
+	if s := result[0].String(); s != "i-01234567890abcdef (web, running, 10.0.0.1)" {
+		t.Errorf("Invalid instance string %v", s)
+	}
+
+	// no instance
+	mock = &MockAPI{DescribeInstancesOutput: &ec2.DescribeInstancesOutput{}}
+	result, err = FindInstancesByAddress(mock, context.Background(), "10.0.0.1")
+	if err != nil || len(result) != 0 {
+		t.Errorf("Instance must not be found (%v, %v)", result, err)
+	}
+}
+
+func Test_GetEICEndpointIdsByVpc(t *testing.T) {
+	var mock = &MockAPI{
+		DescribeInstanceConnectEndpointsOutput: &ec2.DescribeInstanceConnectEndpointsOutput{
+			InstanceConnectEndpoints: []types.Ec2InstanceConnectEndpoint{
+				{InstanceConnectEndpointId: aws.String("eice-1234567890"), VpcId: aws.String("vpc-12345678")},
+			},
+		},
+	}
+	result, err := GetEICEndpointIdsByVpc(mock, context.Background())
+	if err != nil {
+		t.Errorf("Failed to get EIC Endpoint VPCs (%v)", err)
+	}
+	if result["vpc-12345678"] != "eice-1234567890" || result["vpc-87654321"] != "" {
+		t.Errorf("Invalid EIC Endpoint VPCs %v", result)
+	}
+}
@@ -0,0 +1,70 @@
+// Code generated by gen_partitions.go; DO NOT EDIT.
+
+package ec2
+
+import "regexp"
+
+var embeddedPartitions = []partition{
+	{Id: "aws", GlobalRegion: "us-east-1", RegionRegex: regexp.MustCompile("^(us|eu|ap|sa|ca|me|af|il|mx)\\-\\w+\\-\\d+$"), Regions: []string{
+		"af-south-1",
+		"ap-east-1",
+		"ap-east-2",
+		"ap-northeast-1",
+		"ap-northeast-2",
+		"ap-northeast-3",
+		"ap-south-1",
+		"ap-south-2",
+		"ap-southeast-1",
+		"ap-southeast-2",
+		"ap-southeast-3",
+		"ap-southeast-4",
+		"ap-southeast-5",
+		"ap-southeast-6",
+		"ap-southeast-7",
+		"ca-central-1",
+		"ca-west-1",
+		"eu-central-1",
+		"eu-central-2",
+		"eu-north-1",
+		"eu-south-1",
+		"eu-south-2",
+		"eu-west-1",
+		"eu-west-2",
+		"eu-west-3",
+		"il-central-1",
+		"me-central-1",
+		"me-south-1",
+		"mx-central-1",
+		"sa-east-1",
+		"us-east-1",
+		"us-east-2",
+		"us-west-1",
+		"us-west-2",
+	}},
+	{Id: "aws-cn", GlobalRegion: "cn-northwest-1", RegionRegex: regexp.MustCompile("^cn\\-\\w+\\-\\d+$"), Regions: []string{
+		"cn-north-1",
+		"cn-northwest-1",
+	}},
+	{Id: "aws-eusc", GlobalRegion: "eusc-de-east-1", RegionRegex: regexp.MustCompile("^eusc\\-(de)\\-\\w+\\-\\d+$"), Regions: []string{
+		"eusc-de-east-1",
+	}},
+	{Id: "aws-iso", GlobalRegion: "us-iso-east-1", RegionRegex: regexp.MustCompile("^us\\-iso\\-\\w+\\-\\d+$"), Regions: []string{
+		"us-iso-east-1",
+		"us-iso-west-1",
+	}},
+	{Id: "aws-iso-b", GlobalRegion: "us-isob-east-1", RegionRegex: regexp.MustCompile("^us\\-isob\\-\\w+\\-\\d+$"), Regions: []string{
+		"us-isob-east-1",
+		"us-isob-west-1",
+	}},
+	{Id: "aws-iso-e", GlobalRegion: "eu-isoe-west-1", RegionRegex: regexp.MustCompile("^eu\\-isoe\\-\\w+\\-\\d+$"), Regions: []string{
+		"eu-isoe-west-1",
+	}},
+	{Id: "aws-iso-f", GlobalRegion: "us-isof-south-1", RegionRegex: regexp.MustCompile("^us\\-isof\\-\\w+\\-\\d+$"), Regions: []string{
+		"us-isof-east-1",
+		"us-isof-south-1",
+	}},
+	{Id: "aws-us-gov", GlobalRegion: "us-gov-west-1", RegionRegex: regexp.MustCompile("^us\\-gov\\-\\w+\\-\\d+$"), Regions: []string{
+		"us-gov-east-1",
+		"us-gov-west-1",
+	}},
+}
@@ -0,0 +1,50 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// the password data is usually generated within this period after launch
+const passwordGenerationPeriod = 15 * time.Minute
+
+var (
+	ErrPasswordDataNotGenerated = errors.New("EC2 PasswordData is not generated yet")
+	ErrPasswordDataNoKeyPair    = errors.New("instance is launched without a key pair, so EC2 PasswordData is never generated")
+	ErrPasswordDataUnavailable  = errors.New("EC2 PasswordData is not available (the password may be changed or the AMI does not generate it)")
+)
+
+// CheckPasswordData returns nil when the password data is available.
+// Otherwise it returns ErrPasswordDataNotGenerated for the recently launched instance, ErrPasswordDataNoKeyPair or ErrPasswordDataUnavailable.
+// The instance is recently launched within the period (e.g. the wait timeout) or the usual generation period, whichever is longer.
+func CheckPasswordData(api EC2API, ctx context.Context, instanceId string, period time.Duration) error {
+	found, err := HasPasswordData(api, ctx, instanceId)
+	if err != nil {
+		return err
+	}
+	if found {
+		return nil
+	}
+
+	input := &ec2.DescribeInstancesInput{InstanceIds: []string{instanceId}}
+	output, err := api.DescribeInstances(ctx, input)
+	if err != nil {
+		return err
+	}
+	if len(output.Reservations) == 0 || len(output.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("instance %v not found", instanceId)
+	}
+	instance := output.Reservations[0].Instances[0]
+	if aws.ToString(instance.KeyName) == "" {
+		return ErrPasswordDataNoKeyPair
+	}
+	if instance.LaunchTime != nil && time.Since(*instance.LaunchTime) < max(period, passwordGenerationPeriod) {
+		return ErrPasswordDataNotGenerated
+	}
+	return ErrPasswordDataUnavailable
+}
@@ -0,0 +1,166 @@
+package ec2
+
+//go:generate go run gen_partitions.go
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/stknohg/ec2rdp/internal/cache"
+)
+
+// timeout of DescribeRegions not to block the command when offline
+const describeRegionsTimeout = 5 * time.Second
+
+// Region opt-in statuses
+const (
+	OptInNotRequired = "opt-in-not-required"
+	OptedIn          = "opted-in"
+	NotOptedIn       = "not-opted-in"
+)
+
+type partition struct {
+	Id           string
+	GlobalRegion string         // the region enabled in all accounts of the partition
+	RegionRegex  *regexp.Regexp // compiled once in the generated table
+	Regions      []string
+}
+
+// Region is the region name and its opt-in status. OptInStatus is empty when the region is from the embedded list.
+type Region struct {
+	Name        string `json:"name"`
+	OptInStatus string `json:"optInStatus"`
+}
+
+// RegionProvider returns the regions with DescribeRegions, and falls back to the embedded list when it fails.
+type RegionProvider struct {
+	API      EC2API
+	Cache    *cache.Cache                  // optional
+	CacheKey string                        // distinguishes the cached regions (e.g. profile name)
+	Logf     func(format string, a ...any) // optional. reports the fallback to the embedded list
+}
+
+// PartitionOf returns the partition ID of the region. "aws" is returned for the unknown region.
+func PartitionOf(region string) string {
+	for _, p := range embeddedPartitions {
+		if slices.Contains(p.Regions, region) {
+			return p.Id
+		}
+	}
+	for _, p := range embeddedPartitions {
+		if p.RegionRegex.MatchString(region) {
+			return p.Id
+		}
+	}
+	return "aws"
+}
+
+// EmbeddedRegions returns the regions of the partition generated from aws-sdk-go-v2 endpoint metadata.
+// All partitions are returned when the partition is empty.
+func EmbeddedRegions(partitionId string) []Region {
+	regions := []Region{}
+	for _, p := range embeddedPartitions {
+		if partitionId != "" && p.Id != partitionId {
+			continue
+		}
+		for _, r := range p.Regions {
+			regions = append(regions, Region{Name: r})
+		}
+	}
+	return regions
+}
+
+// Regions returns the regions of the partition which the API client belongs to, including the regions not opted in.
+func (p *RegionProvider) Regions(ctx context.Context, clientRegion string) []Region {
+	partitionId := PartitionOf(clientRegion)
+	key := fmt.Sprintf("%v|%v", p.CacheKey, partitionId)
+	regions := []Region{}
+	if p.Cache != nil && p.Cache.Get(key, &regions) {
+		return regions
+	}
+	regions, err := p.describeRegions(ctx, partitionId)
+	if err != nil {
+		p.logf("Failed to describe regions, use the embedded region list (%v)\n", err)
+		return EmbeddedRegions(partitionId)
+	}
+	if p.Cache != nil {
+		p.Cache.Set(key, regions)
+	}
+	return regions
+}
+
+// ValidateRegionName returns the error when the region is neither in the embedded list nor matches any partition.
+// It is checked without API calls.
+func ValidateRegionName(region string) error {
+	if !slices.ContainsFunc(EmbeddedRegions(""), func(r Region) bool { return r.Name == region }) && !matchesAnyPartition(region) {
+		return fmt.Errorf("region %v is invalid", region)
+	}
+	return nil
+}
+
+// Validate returns the error when the region does not exist or is not opted in.
+func (p *RegionProvider) Validate(ctx context.Context, region string) error {
+	if err := ValidateRegionName(region); err != nil {
+		return err
+	}
+	regions := p.Regions(ctx, region)
+	i := slices.IndexFunc(regions, func(r Region) bool { return r.Name == region })
+	if i < 0 {
+		return fmt.Errorf("region %v is not found in partition %v", region, PartitionOf(region))
+	}
+	if regions[i].OptInStatus == NotOptedIn {
+		return fmt.Errorf("region %v is not enabled in your account (opt-in required)", region)
+	}
+	return nil
+}
+
+// describeRegions calls DescribeRegions in the global region of the partition,
+// because the call in the region not opted in fails.
+func (p *RegionProvider) describeRegions(ctx context.Context, partitionId string) ([]Region, error) {
+	ctx, cancel := context.WithTimeout(ctx, describeRegionsTimeout)
+	defer cancel()
+	output, err := p.API.DescribeRegions(ctx, &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)}, func(o *ec2.Options) {
+		if region := globalRegionOf(partitionId); region != "" {
+			o.Region = region
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	regions := []Region{}
+	for _, r := range output.Regions {
+		regions = append(regions, Region{Name: aws.ToString(r.RegionName), OptInStatus: aws.ToString(r.OptInStatus)})
+	}
+	slices.SortFunc(regions, func(a, b Region) int { return strings.Compare(a.Name, b.Name) })
+	return regions, nil
+}
+
+func (p *RegionProvider) logf(format string, a ...any) {
+	if p.Logf != nil {
+		p.Logf(format, a...)
+	}
+}
+
+func globalRegionOf(partitionId string) string {
+	for _, p := range embeddedPartitions {
+		if p.Id == partitionId {
+			return p.GlobalRegion
+		}
+	}
+	return ""
+}
+
+func matchesAnyPartition(region string) bool {
+	for _, p := range embeddedPartitions {
+		if p.RegionRegex.MatchString(region) {
+			return true
+		}
+	}
+	return false
+}
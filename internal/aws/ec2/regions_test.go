@@ -0,0 +1,135 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stknohg/ec2rdp/internal/cache"
+)
+
+func Test_PartitionOf(t *testing.T) {
+	tests := map[string]string{
+		"ap-northeast-1": "aws",
+		"us-gov-west-1":  "aws-us-gov",
+		"cn-north-1":     "aws-cn",
+		"us-isob-east-1": "aws-iso-b",
+		"ap-future-9":    "aws",
+		"unknown":        "aws",
+	}
+	for region, expected := range tests {
+		if p := PartitionOf(region); p != expected {
+			t.Errorf("Partition of %v must be %v (%v)", region, expected, p)
+		}
+	}
+	if len(EmbeddedRegions("aws-cn")) == 0 || len(EmbeddedRegions("")) <= len(EmbeddedRegions("aws")) {
+		t.Error("Invalid embedded regions")
+	}
+}
+
+func Test_RegionProvider(t *testing.T) {
+	var mock = &MockAPI{
+		DescribeRegionsOutput: &ec2.DescribeRegionsOutput{
+			Regions: []types.Region{
+				{RegionName: aws.String("us-east-1"), OptInStatus: aws.String(OptInNotRequired)},
+				{RegionName: aws.String("ap-east-1"), OptInStatus: aws.String(NotOptedIn)},
+				{RegionName: aws.String("af-south-1"), OptInStatus: aws.String(OptedIn)},
+			},
+		},
+	}
+	c := &cache.Cache{Dir: t.TempDir(), TTL: time.Hour}
+	provider := &RegionProvider{API: mock, Cache: c, CacheKey: "test"}
+	regions := provider.Regions(context.Background(), "us-east-1")
+	if len(regions) != 3 || regions[0].Name != "af-south-1" || regions[1].OptInStatus != NotOptedIn {
+		t.Errorf("Invalid regions %v", regions)
+	}
+
+	// validate
+	ctx := context.Background()
+	if err := provider.Validate(ctx, "af-south-1"); err != nil {
+		t.Errorf("Opted in region must be valid (%v)", err)
+	}
+	if err := provider.Validate(ctx, "ap-east-1"); err == nil {
+		t.Error("Failed to detect not opted in region")
+	}
+	if err := provider.Validate(ctx, "eu-west-1"); err == nil {
+		t.Error("Failed to detect the region not in account")
+	}
+	if err := provider.Validate(ctx, "us-eats1"); err == nil {
+		t.Error("Failed to detect invalid region")
+	}
+
+	// cached regions are used
+	mock.DescribeRegionsOutput = nil
+	mock.Error = errors.New("must not be called")
+	if regions := provider.Regions(ctx, "us-west-2"); len(regions) != 3 {
+		t.Errorf("Cached regions must be used (%v)", regions)
+	}
+
+	// embedded regions are used when offline
+	provider = &RegionProvider{API: mock}
+	regions = provider.Regions(ctx, "us-gov-west-1")
+	if len(regions) == 0 || regions[0].OptInStatus != "" {
+		t.Errorf("Invalid embedded regions %v", regions)
+	}
+	if err := provider.Validate(ctx, "us-gov-east-1"); err != nil {
+		t.Errorf("Embedded region must be valid (%v)", err)
+	}
+	if err := provider.Validate(ctx, "us-gov-north-9"); err == nil {
+		t.Error("Failed to detect the region not in embedded regions")
+	}
+}
+
+// regionRecorder records the client region of DescribeRegions call.
+type regionRecorder struct {
+	*MockAPI
+	Region string
+}
+
+func (m *regionRecorder) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	o := ec2.Options{Region: "ap-east-1"}
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	m.Region = o.Region
+	return m.MockAPI.DescribeRegions(ctx, params, optFns...)
+}
+
+func Test_RegionProvider_GlobalRegion(t *testing.T) {
+	// DescribeRegions is called in the global region of the partition, not in the region not opted in
+	mock := &regionRecorder{MockAPI: &MockAPI{
+		DescribeRegionsOutput: &ec2.DescribeRegionsOutput{
+			Regions: []types.Region{{RegionName: aws.String("ap-east-1"), OptInStatus: aws.String(NotOptedIn)}},
+		},
+	}}
+	provider := &RegionProvider{API: mock}
+	if err := provider.Validate(context.Background(), "ap-east-1"); err == nil {
+		t.Error("Failed to detect not opted in region")
+	}
+	if mock.Region != "us-east-1" {
+		t.Errorf("DescribeRegions must be called in us-east-1 (%v)", mock.Region)
+	}
+	provider.Regions(context.Background(), "cn-north-1")
+	if mock.Region != "cn-northwest-1" {
+		t.Errorf("DescribeRegions must be called in cn-northwest-1 (%v)", mock.Region)
+	}
+
+	// the fallback to the embedded list is reported
+	logs := []string{}
+	mock.Error = errors.New("offline")
+	provider = &RegionProvider{API: mock, Logf: func(format string, a ...any) {
+		logs = append(logs, fmt.Sprintf(format, a...))
+	}}
+	if err := provider.Validate(context.Background(), "ap-east-1"); err != nil {
+		t.Errorf("Embedded region must be valid (%v)", err)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "offline") {
+		t.Errorf("Fallback must be reported (%v)", logs)
+	}
+}
@@ -0,0 +1,70 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func GetInstanceState(api EC2API, ctx context.Context, instanceId string) (types.InstanceStateName, error) {
+	input := &ec2.DescribeInstancesInput{InstanceIds: []string{instanceId}}
+	output, err := api.DescribeInstances(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if len(output.Reservations) == 0 || len(output.Reservations[0].Instances) == 0 {
+		return "", fmt.Errorf("instance %v not found", instanceId)
+	}
+	state := output.Reservations[0].Instances[0].State
+	if state == nil {
+		return "", fmt.Errorf("failed to get instance %v state", instanceId)
+	}
+	return state.Name, nil
+}
+
+// StartInstance starts the stopped instance. Hibernated instances are resumed.
+func StartInstance(api EC2API, ctx context.Context, instanceId string) error {
+	input := &ec2.StartInstancesInput{InstanceIds: []string{instanceId}}
+	_, err := api.StartInstances(ctx, input)
+	return err
+}
+
+func StopInstance(api EC2API, ctx context.Context, instanceId string) error {
+	input := &ec2.StopInstancesInput{InstanceIds: []string{instanceId}}
+	_, err := api.StopInstances(ctx, input)
+	return err
+}
+
+// GetInstanceStatusChecks returns the instance and system status check results (e.g. ok, initializing).
+func GetInstanceStatusChecks(api EC2API, ctx context.Context, instanceId string) (types.SummaryStatus, types.SummaryStatus, error) {
+	input := &ec2.DescribeInstanceStatusInput{InstanceIds: []string{instanceId}, IncludeAllInstances: aws.Bool(true)}
+	output, err := api.DescribeInstanceStatus(ctx, input)
+	if err != nil {
+		return "", "", err
+	}
+	if len(output.InstanceStatuses) == 0 {
+		return "", "", fmt.Errorf("instance %v not found", instanceId)
+	}
+	status := output.InstanceStatuses[0]
+	var instanceStatus, systemStatus types.SummaryStatus
+	if status.InstanceStatus != nil {
+		instanceStatus = status.InstanceStatus.Status
+	}
+	if status.SystemStatus != nil {
+		systemStatus = status.SystemStatus.Status
+	}
+	return instanceStatus, systemStatus, nil
+}
+
+// HasPasswordData reports whether the encrypted administrator password is available.
+func HasPasswordData(api EC2API, ctx context.Context, instanceId string) (bool, error) {
+	input := &ec2.GetPasswordDataInput{InstanceId: &instanceId}
+	output, err := api.GetPasswordData(ctx, input)
+	if err != nil {
+		return false, err
+	}
+	return aws.ToString(output.PasswordData) != "", nil
+}
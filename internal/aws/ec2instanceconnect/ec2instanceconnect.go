@@ -2,49 +2,219 @@ package ec2instanceconnect
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/gorilla/websocket"
+	"github.com/stknohg/ec2rdp/internal/aws/endpoint"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
 )
 
-func OpenTunnel(cfg aws.Config, ctx context.Context, endpointId string, endpointDnsName string, privateIpAddress string, localPort int, remotePort int) (int, error) {
-	// execute aws ec2-instance-connect open-tunnel command.
-	args := []string{}
-	args = append(args,
-		"ec2-instance-connect", "open-tunnel",
-		"--instance-connect-endpoint-id", endpointId,
-		"--instance-connect-endpoint-dns-name", endpointDnsName,
-		"--private-ip-address", privateIpAddress,
-		"--local-port", strconv.Itoa(localPort),
-		"--remote-port", strconv.Itoa(remotePort),
-	)
-	cmd := exec.Command("aws", args...)
-	// pass environment variables in case MFA is required
-	cred, _ := cfg.Credentials.Retrieve(ctx)
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("AWS_ACCESS_KEY_ID=%v", cred.AccessKeyID),
-		fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%v", cred.SecretAccessKey),
-		fmt.Sprintf("AWS_SESSION_TOKEN=%v", cred.SessionToken),
-		fmt.Sprintf("AWS_REGION=%v", cfg.Region),
-	)
-	// start process
-	err := cmd.Start()
+const (
+	signingName = "ec2-instance-connect"
+	// presigned URL is valid for 60 seconds like AWS CLI.
+	presignExpires = "60"
+	// SHA-256 hash of empty payload
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Tunnel relays local TCP connections to the instance via EC2 Instance Connect Endpoint.
+// Each TCP connection uses its own WebSocket connection like `aws ec2-instance-connect open-tunnel`.
+// It gets ready when listening the local port because WebSocket connections are opened on demand.
+type Tunnel struct {
+	tunnel.State
+	Credentials      aws.CredentialsProvider
+	Region           string
+	EndpointId       string
+	EndpointURL      string // e.g. wss://eice-xxx.xxx.ec2-instance-connect-endpoint.<region>.amazonaws.com
+	PrivateIpAddress string
+	RemotePort       int
+	LocalHost        string
+	LocalPort        int
+
+	dialer   *websocket.Dialer
+	listener net.Listener
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewTunnel(cfg aws.Config, endpointId string, endpointDnsName string, privateIpAddress string, localPort int, remotePort int) *Tunnel {
+	endpointURL := fmt.Sprintf("wss://%v", endpointDnsName)
+	if u, ok := endpoint.ResolveService(cfg, endpoint.EC2InstanceConnect); ok {
+		endpointURL = endpoint.WebSocketURL(u)
+	}
+	return &Tunnel{
+		Credentials:      cfg.Credentials,
+		Region:           cfg.Region,
+		EndpointId:       endpointId,
+		EndpointURL:      endpointURL,
+		PrivateIpAddress: privateIpAddress,
+		RemotePort:       remotePort,
+		LocalHost:        "localhost",
+		LocalPort:        localPort,
+	}
+}
+
+// Open starts listening the local port.
+func (t *Tunnel) Open(ctx context.Context) error {
+	return t.Listen(ctx, t.LocalHost, t.LocalPort)
+}
+
+// Listen starts listening the local port and relaying connections in background.
+func (t *Tunnel) Listen(ctx context.Context, localHost string, localPort int) error {
+	if t.Credentials == nil {
+		return errors.New("no AWS credentials specified")
+	}
+	if t.Region == "" {
+		return errors.New("no region name specified")
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(localHost, strconv.Itoa(localPort)))
 	if err != nil {
-		return -1, err
+		return err
+	}
+	t.listener = listener
+	if t.dialer == nil {
+		t.dialer = &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: 30 * time.Second}
+	}
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	t.wg.Add(1)
+	go t.acceptLoop()
+	t.SetReady()
+	return nil
+}
+
+// Close stops listening and closes all relaying connections.
+func (t *Tunnel) Close() error {
+	if t.listener == nil {
+		return errors.New("tunnel is not opened")
+	}
+	t.cancel()
+	err := t.listener.Close()
+	t.wg.Wait()
+	t.SetDone(nil)
+	return err
+}
+
+func (t *Tunnel) String() string {
+	return fmt.Sprintf("WebSocket tunnel to %v", t.EndpointId)
+}
+
+func (t *Tunnel) acceptLoop() {
+	defer t.wg.Done()
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			if t.ctx.Err() == nil {
+				// listener is closed unexpectedly
+				t.SetDone(err)
+			}
+			return
+		}
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			err := t.relay(conn)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "WebSocket tunnel error: %v\n", err)
+			}
+		}()
 	}
-	return cmd.Process.Pid, nil
 }
 
-func CloseTunnel(pid int) error {
-	if pid < 0 {
-		return fmt.Errorf("invalid pid specified")
+func (t *Tunnel) relay(conn net.Conn) error {
+	defer conn.Close()
+	signedURL, err := t.presignURL(t.ctx)
+	if err != nil {
+		return err
 	}
-	proc, err := os.FindProcess(pid)
+	ws, resp, err := t.dialer.DialContext(t.ctx, signedURL, nil)
 	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to open WebSocket (status=%v)", resp.Status)
+		}
 		return err
 	}
-	return proc.Kill()
+	defer ws.Close()
+
+	// close both connections when the tunnel is closed.
+	stop := context.AfterFunc(t.ctx, func() {
+		conn.Close()
+		ws.Close()
+	})
+	defer stop()
+
+	// WebSocket -> TCP
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer conn.Close()
+		for {
+			_, r, err := ws.NextReader()
+			if err != nil {
+				return
+			}
+			if _, err := io.Copy(conn, r); err != nil {
+				return
+			}
+		}
+	}()
+
+	// TCP -> WebSocket
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if werr := ws.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	ws.Close()
+	<-done
+	return nil
+}
+
+// presignURL returns the WebSocket URL signed with SigV4 query parameters.
+// ref : https://github.com/aws/aws-cli/blob/v2/awscli/customizations/ec2instanceconnect/websocket.py
+func (t *Tunnel) presignURL(ctx context.Context) (string, error) {
+	u, err := url.Parse(t.EndpointURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/openTunnel"
+	query := url.Values{}
+	query.Set("instanceConnectEndpointId", t.EndpointId)
+	query.Set("remotePort", strconv.Itoa(t.RemotePort))
+	query.Set("privateIpAddress", t.PrivateIpAddress)
+	query.Set("X-Amz-Expires", presignExpires)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	cred, err := t.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", err
+	}
+	signedURL, _, err := v4.NewSigner().PresignHTTP(ctx, cred, req, emptyPayloadHash, signingName, t.Region, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return signedURL, nil
 }
@@ -0,0 +1,204 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Service IDs of AWS SDK, which are used in AWS_ENDPOINT_URL_<SERVICE> environment variables and services sections.
+const (
+	EC2                = "EC2"
+	SSM                = "SSM"
+	STS                = "STS"
+	SSMMessages        = "SSMMessages"          // data channel of SSM tunnel
+	EC2InstanceConnect = "EC2 Instance Connect" // WebSocket of EICE tunnel
+)
+
+var Services = []string{EC2, SSM, STS, SSMMessages, EC2InstanceConnect}
+
+// Flags is the endpoint URLs of --endpoint-url flags.
+// The key is the normalized service ID (e.g. ec2_instance_connect), and the empty key is for all API services.
+type Flags map[string]string
+
+// ParseFlags parses [SERVICE=]URL values. SERVICE is the service ID like ec2, ssm, sts, ssmmessages or ec2-instance-connect.
+func ParseFlags(values []string) (Flags, error) {
+	flags := Flags{}
+	for _, v := range values {
+		service, endpointURL, found := strings.Cut(v, "=")
+		if !found || strings.Contains(service, "://") {
+			service, endpointURL = "", v
+		}
+		key := ""
+		if service != "" {
+			key = normalize(service)
+			if !isService(key) {
+				return nil, fmt.Errorf("invalid service %q of endpoint URL (set %v)", service, strings.Join(ServiceNames(), ", "))
+			}
+		}
+		u, err := url.Parse(endpointURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint URL %q", endpointURL)
+		}
+		switch u.Scheme {
+		case "http", "https", "ws", "wss":
+		default:
+			return nil, fmt.Errorf("invalid endpoint URL scheme %q", endpointURL)
+		}
+		if _, ok := flags[key]; ok {
+			return nil, fmt.Errorf("endpoint URL of %v is duplicated", orAll(service))
+		}
+		flags[key] = endpointURL
+	}
+	return flags, nil
+}
+
+// GetServiceBaseEndpoint implements the config source of AWS SDK.
+func (f Flags) GetServiceBaseEndpoint(ctx context.Context, sdkID string) (string, bool, error) {
+	u, ok := f[normalize(sdkID)]
+	return u, ok, nil
+}
+
+// With returns the config with --endpoint-url flags, which precede the environment variables and config files.
+func With(cfg aws.Config, flags Flags) aws.Config {
+	if len(flags) == 0 {
+		return cfg
+	}
+	c := cfg.Copy()
+	if u, ok := flags[""]; ok {
+		c.BaseEndpoint = aws.String(u)
+	}
+	c.ConfigSources = append([]any{flags}, cfg.ConfigSources...)
+	return c
+}
+
+// Resolve returns the endpoint URL of the API service in the same precedence as AWS SDK.
+// --endpoint-url flags, AWS_ENDPOINT_URL_<SERVICE>, AWS_ENDPOINT_URL, services section and endpoint_url of the profile.
+func Resolve(cfg aws.Config, sdkID string) (string, bool) {
+	if f, ok := flagsOf(cfg); ok {
+		if u, ok := f[normalize(sdkID)]; ok {
+			return u, true
+		}
+		if u, ok := f[""]; ok {
+			return u, true
+		}
+	}
+	if ignored(cfg) {
+		return "", false
+	}
+	if u := os.Getenv("AWS_ENDPOINT_URL_" + envName(sdkID)); u != "" {
+		return u, true
+	}
+	if u := os.Getenv("AWS_ENDPOINT_URL"); u != "" {
+		return u, true
+	}
+	if u, ok := sharedServiceEndpoint(cfg, sdkID); ok {
+		return u, true
+	}
+	if cfg.BaseEndpoint != nil && *cfg.BaseEndpoint != "" {
+		return *cfg.BaseEndpoint, true
+	}
+	return "", false
+}
+
+// ResolveService returns the endpoint URL configured only for the service, and is used for the tunnels.
+// The endpoint URL for all services is not used because the tunnels do not connect to the API endpoints.
+func ResolveService(cfg aws.Config, sdkID string) (string, bool) {
+	if f, ok := flagsOf(cfg); ok {
+		if u, ok := f[normalize(sdkID)]; ok {
+			return u, true
+		}
+	}
+	if ignored(cfg) {
+		return "", false
+	}
+	if u := os.Getenv("AWS_ENDPOINT_URL_" + envName(sdkID)); u != "" {
+		return u, true
+	}
+	return sharedServiceEndpoint(cfg, sdkID)
+}
+
+// WebSocketURL returns the URL whose scheme is ws or wss.
+func WebSocketURL(endpointURL string) string {
+	if rest, found := strings.CutPrefix(endpointURL, "https://"); found {
+		return "wss://" + rest
+	}
+	if rest, found := strings.CutPrefix(endpointURL, "http://"); found {
+		return "ws://" + rest
+	}
+	return endpointURL
+}
+
+func flagsOf(cfg aws.Config) (Flags, bool) {
+	for _, src := range cfg.ConfigSources {
+		if f, ok := src.(Flags); ok {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func ignored(cfg aws.Config) bool {
+	type ignoreProvider interface {
+		GetIgnoreConfiguredEndpoints(ctx context.Context) (bool, bool, error)
+	}
+	for _, src := range cfg.ConfigSources {
+		if p, ok := src.(ignoreProvider); ok {
+			value, found, err := p.GetIgnoreConfiguredEndpoints(context.Background())
+			if err == nil && found {
+				return value
+			}
+		}
+	}
+	return false
+}
+
+func sharedServiceEndpoint(cfg aws.Config, sdkID string) (string, bool) {
+	for _, src := range cfg.ConfigSources {
+		if sc, ok := src.(config.SharedConfig); ok {
+			u, found, err := sc.GetServiceBaseEndpoint(context.Background(), sdkID)
+			return u, found && err == nil
+		}
+	}
+	return "", false
+}
+
+// normalize returns the key of services section (e.g. ec2_instance_connect).
+func normalize(service string) string {
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(service))
+}
+
+// envName returns the suffix of AWS_ENDPOINT_URL_<SERVICE> (e.g. EC2_INSTANCE_CONNECT).
+func envName(sdkID string) string {
+	return strings.ToUpper(normalize(sdkID))
+}
+
+func isService(key string) bool {
+	for _, s := range Services {
+		if normalize(s) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceNames returns the service names of --endpoint-url flag.
+func ServiceNames() []string {
+	names := []string{}
+	for _, s := range Services {
+		names = append(names, strings.ReplaceAll(normalize(s), "_", "-"))
+	}
+	return names
+}
+
+func orAll(service string) string {
+	if service == "" {
+		return "all services"
+	}
+	return service
+}
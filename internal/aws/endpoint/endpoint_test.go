@@ -0,0 +1,103 @@
+package endpoint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+func Test_ParseFlags(t *testing.T) {
+	flags, err := ParseFlags([]string{"http://localhost:4566", "ec2=https://vpce-1.ec2.ap-northeast-1.vpce.amazonaws.com", "EC2-Instance-Connect=wss://localhost:8443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags[""] != "http://localhost:4566" || flags["ec2"] == "" || flags["ec2_instance_connect"] != "wss://localhost:8443" {
+		t.Errorf("Invalid flags %v", flags)
+	}
+	for _, values := range [][]string{
+		{"s3=http://localhost:4566"},
+		{"ec2=localhost:4566"},
+		{"ec2=ftp://localhost"},
+		{"ec2=http://localhost:4566", "ec2=http://localhost:4567"},
+	} {
+		if _, err := ParseFlags(values); err == nil {
+			t.Errorf("Failed to detect invalid flags %v", values)
+		}
+	}
+}
+
+func loadConfig(t *testing.T) aws.Config {
+	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithSharedConfigProfile("local"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func Test_Resolve(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(configFile, []byte(`[profile local]
+region = us-east-1
+services = local
+
+[services local]
+ssm =
+  endpoint_url = http://localhost:4566
+ec2_instance_connect =
+  endpoint_url = https://localhost:8443
+`), 0600)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	t.Setenv("AWS_ENDPOINT_URL_EC2", "http://localhost:5000")
+
+	cfg := loadConfig(t)
+	if u, _ := Resolve(cfg, EC2); u != "http://localhost:5000" {
+		t.Errorf("Environment variable must be used (%v)", u)
+	}
+	if u, _ := Resolve(cfg, SSM); u != "http://localhost:4566" {
+		t.Errorf("services section must be used (%v)", u)
+	}
+	if u, ok := Resolve(cfg, STS); ok {
+		t.Errorf("Default endpoint must be used (%v)", u)
+	}
+	if u, _ := ResolveService(cfg, EC2InstanceConnect); u != "https://localhost:8443" {
+		t.Errorf("services section must be used for tunnel (%v)", u)
+	}
+
+	// flags precede the others, and the endpoint for all services is not used for tunnels
+	flags, _ := ParseFlags([]string{"http://localhost:9000", "ssm=http://localhost:9001"})
+	cfg = With(cfg, flags)
+	for service, want := range map[string]string{EC2: "http://localhost:9000", SSM: "http://localhost:9001", STS: "http://localhost:9000"} {
+		if u, _ := Resolve(cfg, service); u != want {
+			t.Errorf("%v: got %v, want %v", service, u, want)
+		}
+	}
+	if u, ok := ResolveService(cfg, SSMMessages); ok {
+		t.Errorf("Endpoint for all services must not be used for tunnel (%v)", u)
+	}
+
+	// configured endpoints are ignored
+	t.Setenv("AWS_IGNORE_CONFIGURED_ENDPOINT_URLS", "true")
+	cfg = loadConfig(t)
+	if u, ok := Resolve(cfg, SSM); ok {
+		t.Errorf("Configured endpoint must be ignored (%v)", u)
+	}
+}
+
+func Test_WebSocketURL(t *testing.T) {
+	tests := map[string]string{
+		"https://localhost:8443": "wss://localhost:8443",
+		"http://localhost:4566":  "ws://localhost:4566",
+		"wss://localhost":        "wss://localhost",
+	}
+	for value, want := range tests {
+		if got := WebSocketURL(value); got != want {
+			t.Errorf("WebSocketURL(%v) = %v, want %v", value, got, want)
+		}
+	}
+}
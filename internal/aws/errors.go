@@ -0,0 +1,71 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	ErrProfileNotFound    = errors.New("AWS profile is not found")
+	ErrNoCredentials      = errors.New("AWS credentials are not found")
+	ErrExpiredCredentials = errors.New("AWS credentials are expired")
+	ErrInvalidCredentials = errors.New("AWS credentials are invalid")
+	ErrNoRegion           = errors.New("AWS region is not set")
+)
+
+// DescribeError maps the common configuration and credential errors to the actionable messages.
+// The other errors are returned as they are.
+func DescribeError(profileName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	profileName = currentProfileName(profileName)
+	var notExist config.SharedConfigProfileNotExistError
+	var invalidToken *ssocreds.InvalidTokenError
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &notExist):
+		return fmt.Errorf("%w (%v)\nCheck --profile flag or AWS_PROFILE environment variable. The profiles are listed by `aws configure list-profiles`", ErrProfileNotFound, notExist.Profile)
+	case errors.As(err, &invalidToken), strings.Contains(err.Error(), "cached SSO token is expired"):
+		return fmt.Errorf("%w (IAM Identity Center session of profile %v)\nTry again to login to IAM Identity Center", ErrExpiredCredentials, profileName)
+	case errors.As(err, &apiErr) && isExpiredErrorCode(apiErr.ErrorCode()):
+		return fmt.Errorf("%w (profile %v, %v)\nRefresh the session token of the profile or the environment variables and try again", ErrExpiredCredentials, profileName, apiErr.ErrorCode())
+	case errors.As(err, &apiErr) && isInvalidErrorCode(apiErr.ErrorCode()):
+		return fmt.Errorf("%w (profile %v, %v)\nCheck the access key of the profile or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables", ErrInvalidCredentials, profileName, apiErr.ErrorCode())
+	case strings.Contains(err.Error(), "no EC2 IMDS role found"):
+		return fmt.Errorf("%w\nUse --profile flag or AWS_PROFILE environment variable, or run `aws configure` to set the credentials", ErrNoCredentials)
+	}
+	return err
+}
+
+func isExpiredErrorCode(code string) bool {
+	switch code {
+	case "ExpiredToken", "ExpiredTokenException", "RequestExpired":
+		return true
+	}
+	return false
+}
+
+func isInvalidErrorCode(code string) bool {
+	switch code {
+	case "InvalidClientTokenId", "UnrecognizedClientException", "SignatureDoesNotMatch", "AuthFailure":
+		return true
+	}
+	return false
+}
+
+func currentProfileName(profileName string) string {
+	if profileName != "" {
+		return profileName
+	}
+	if v := os.Getenv("AWS_PROFILE"); v != "" {
+		return v
+	}
+	return config.DefaultSharedConfigProfile
+}
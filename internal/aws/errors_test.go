@@ -0,0 +1,39 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+)
+
+func Test_DescribeError(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "")
+	other := errors.New("other error")
+	tests := []struct {
+		name    string
+		err     error
+		want    error
+		message string
+	}{
+		{"profile not found", fmt.Errorf("load: %w", config.SharedConfigProfileNotExistError{Profile: "dev"}), ErrProfileNotFound, "--profile"},
+		{"sso expired", fmt.Errorf("get credentials: %w", &ssocreds.InvalidTokenError{}), ErrExpiredCredentials, "login to IAM Identity Center"},
+		{"expired token", &smithy.GenericAPIError{Code: "ExpiredToken"}, ErrExpiredCredentials, "ExpiredToken"},
+		{"invalid token", &smithy.GenericAPIError{Code: "InvalidClientTokenId"}, ErrInvalidCredentials, "AWS_ACCESS_KEY_ID"},
+		{"no credentials", errors.New("failed to refresh cached credentials, no EC2 IMDS role found, timeout"), ErrNoCredentials, "aws configure"},
+		{"other", other, other, "other error"},
+	}
+	for _, tt := range tests {
+		got := DescribeError("", tt.err)
+		if !errors.Is(got, tt.want) || !strings.Contains(got.Error(), tt.message) {
+			t.Errorf("%v: got %v", tt.name, got)
+		}
+	}
+	if DescribeError("", nil) != nil {
+		t.Error("nil error must be nil")
+	}
+}
@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var ErrMFAUnavailable = errors.New("MFA code can not be prompted")
+
+// PromptMFAToken prompts the MFA code on the terminal.
+// Unlike stscreds.StdinTokenProvider, it does not read ahead stdin, so the following password prompt works.
+func PromptMFAToken(serialNumber string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("%w, stdin is not a terminal (MFA device %v)", ErrMFAUnavailable, serialNumber)
+	}
+	fmt.Fprintf(os.Stderr, "Enter MFA code for %v: ", serialNumber)
+	code, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return parseMFAToken(string(code))
+}
+
+// NoMFAToken fails instead of prompting the MFA code, so only the cached credentials are used (e.g. while completing).
+func NoMFAToken(serialNumber string) (string, error) {
+	return "", fmt.Errorf("%w (MFA device %v)", ErrMFAUnavailable, serialNumber)
+}
+
+func parseMFAToken(value string) (string, error) {
+	code := strings.TrimSpace(value)
+	if code == "" {
+		return "", errors.New("MFA code is empty")
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", errors.New("MFA code must be digits")
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// ListProfiles returns the profile names in the shared config and credentials files.
+// AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE environment variables are respected.
+func ListProfiles() []string {
+	configFile := config.DefaultSharedConfigFilename()
+	if v := os.Getenv("AWS_CONFIG_FILE"); v != "" {
+		configFile = v
+	}
+	credentialsFile := config.DefaultSharedCredentialsFilename()
+	if v := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); v != "" {
+		credentialsFile = v
+	}
+
+	names := map[string]bool{}
+	for _, n := range readSectionNames(configFile) {
+		// config file uses [profile name] except [default]
+		if n == "default" {
+			names[n] = true
+		} else if name, found := strings.CutPrefix(n, "profile "); found {
+			names[strings.TrimSpace(name)] = true
+		}
+	}
+	for _, n := range readSectionNames(credentialsFile) {
+		names[n] = true
+	}
+
+	profiles := []string{}
+	for n := range names {
+		if n != "" {
+			profiles = append(profiles, n)
+		}
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+func readSectionNames(filePath string) []string {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return []string{}
+	}
+	defer f.Close()
+
+	names := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			names = append(names, strings.TrimSpace(line[1:len(line)-1]))
+		}
+	}
+	return names
+}
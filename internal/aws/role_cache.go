@@ -0,0 +1,231 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// the same expiry window as AWS CLI
+const roleCacheExpiryWindow = 15 * time.Minute
+
+// RoleCache is the assumed role credentials cache compatible with AWS CLI (~/.aws/cli/cache/*.json).
+type RoleCache struct {
+	Dir string
+	Now func() time.Time
+}
+
+// DefaultRoleCache returns the cache in AWS CLI cache directory, or nil when the home directory is unknown.
+func DefaultRoleCache() *RoleCache {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return nil
+	}
+	return &RoleCache{Dir: filepath.Join(home, ".aws", "cli", "cache")}
+}
+
+type cachedRole struct {
+	Credentials     cachedCredentials      `json:"Credentials"`
+	AssumedRoleUser *cachedAssumedRoleUser `json:"AssumedRoleUser,omitempty"`
+}
+
+type cachedCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+type cachedAssumedRoleUser struct {
+	AssumedRoleId string `json:"AssumedRoleId"`
+	Arn           string `json:"Arn"`
+}
+
+// RoleCacheKey returns the cache key of AssumeRole parameters, the same as AWS CLI (botocore).
+// It is SHA1 of the sorted JSON of the parameters except RoleSessionName and TokenCode.
+func RoleCacheKey(input *sts.AssumeRoleInput) string {
+	args := map[string]any{"RoleArn": aws.ToString(input.RoleArn)}
+	if input.ExternalId != nil {
+		args["ExternalId"] = *input.ExternalId
+	}
+	if input.SerialNumber != nil {
+		args["SerialNumber"] = *input.SerialNumber
+	}
+	if input.DurationSeconds != nil {
+		args["DurationSeconds"] = *input.DurationSeconds
+	}
+	keys := []string{}
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	// Python json.dumps separators
+	items := []string{}
+	for _, k := range keys {
+		value, _ := json.Marshal(args[k])
+		items = append(items, fmt.Sprintf("%q: %v", k, string(value)))
+	}
+	hash := sha1.Sum([]byte("{" + strings.Join(items, ", ") + "}"))
+	return hex.EncodeToString(hash[:])
+}
+
+// Get returns the cached AssumeRole output, which is not expired.
+func (c *RoleCache) Get(key string) (*sts.AssumeRoleOutput, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	cached := cachedRole{}
+	err = json.Unmarshal(data, &cached)
+	if err != nil || cached.Credentials.AccessKeyId == "" {
+		return nil, false
+	}
+	expiration, err := time.Parse(time.RFC3339, cached.Credentials.Expiration)
+	if err != nil || expiration.Sub(c.now()) < roleCacheExpiryWindow {
+		return nil, false
+	}
+	output := &sts.AssumeRoleOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String(cached.Credentials.AccessKeyId),
+			SecretAccessKey: aws.String(cached.Credentials.SecretAccessKey),
+			SessionToken:    aws.String(cached.Credentials.SessionToken),
+			Expiration:      aws.Time(expiration),
+		},
+	}
+	if cached.AssumedRoleUser != nil {
+		output.AssumedRoleUser = &types.AssumedRoleUser{
+			AssumedRoleId: aws.String(cached.AssumedRoleUser.AssumedRoleId),
+			Arn:           aws.String(cached.AssumedRoleUser.Arn),
+		}
+	}
+	return output, true
+}
+
+// Set writes the AssumeRole output to the cache file, which is readable only by the user.
+func (c *RoleCache) Set(key string, output *sts.AssumeRoleOutput) error {
+	if output == nil || output.Credentials == nil {
+		return nil
+	}
+	cached := cachedRole{
+		Credentials: cachedCredentials{
+			AccessKeyId:     aws.ToString(output.Credentials.AccessKeyId),
+			SecretAccessKey: aws.ToString(output.Credentials.SecretAccessKey),
+			SessionToken:    aws.ToString(output.Credentials.SessionToken),
+			Expiration:      aws.ToTime(output.Credentials.Expiration).UTC().Format(time.RFC3339),
+		},
+	}
+	if output.AssumedRoleUser != nil {
+		cached.AssumedRoleUser = &cachedAssumedRoleUser{
+			AssumedRoleId: aws.ToString(output.AssumedRoleUser.AssumedRoleId),
+			Arn:           aws.ToString(output.AssumedRoleUser.Arn),
+		}
+	}
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.Dir, 0700)
+	if err != nil {
+		return err
+	}
+	// write to the temporary file (0600) and rename it not to read the partial file
+	f, err := os.CreateTemp(c.Dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(key))
+}
+
+func (c *RoleCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+func (c *RoleCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// cachedAssumeRoleClient returns the cached credentials instead of calling AssumeRole.
+// The MFA code is prompted only when AssumeRole is called actually.
+type cachedAssumeRoleClient struct {
+	next          stscreds.AssumeRoleAPIClient
+	cache         *RoleCache
+	tokenProvider func(serialNumber string) (string, error)
+	// AWS CLI does not include the default duration in the cache key
+	durationConfigured bool
+}
+
+// deferredTokenProvider is the token provider of stscreds, the actual MFA code is prompted by cachedAssumeRoleClient.
+func deferredTokenProvider() (string, error) {
+	return "", nil
+}
+
+func (c *cachedAssumeRoleClient) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	keyInput := *params
+	if !c.durationConfigured {
+		keyInput.DurationSeconds = nil
+	}
+	key := RoleCacheKey(&keyInput)
+	if c.cache != nil {
+		if output, ok := c.cache.Get(key); ok {
+			return output, nil
+		}
+	}
+
+	input := *params
+	if input.SerialNumber != nil && aws.ToString(input.TokenCode) == "" {
+		code, err := c.tokenProvider(*input.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+		input.TokenCode = aws.String(code)
+	}
+	output, err := c.next.AssumeRole(ctx, &input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if c.cache != nil {
+		// the credentials are still usable without the cache
+		c.cache.Set(key, output)
+	}
+	return output, nil
+}
+
+// withRoleCache sets the cached client and the MFA prompt to the AssumeRole options.
+// The default duration is 1 hour, the same as AWS CLI.
+func withRoleCache(options *stscreds.AssumeRoleOptions, cache *RoleCache, tokenProvider func(serialNumber string) (string, error)) {
+	options.Client = &cachedAssumeRoleClient{
+		next:               options.Client,
+		cache:              cache,
+		tokenProvider:      tokenProvider,
+		durationConfigured: options.Duration != 0,
+	}
+	if options.Duration == 0 {
+		options.Duration = time.Hour
+	}
+	if options.SerialNumber != nil {
+		options.TokenProvider = deferredTokenProvider
+	}
+}
@@ -0,0 +1,127 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+func Test_RoleCacheKey(t *testing.T) {
+	// expected values are computed by botocore
+	tests := []struct {
+		input *sts.AssumeRoleInput
+		want  string
+	}{
+		{&sts.AssumeRoleInput{RoleArn: aws.String("arn:aws:iam::123456789012:role/rdp"), RoleSessionName: aws.String("session")}, "283bab82730581dcf75c59ed8b7f71f64932603f"},
+		{&sts.AssumeRoleInput{
+			RoleArn:         aws.String("arn:aws:iam::123456789012:role/rdp"),
+			ExternalId:      aws.String("ext"),
+			SerialNumber:    aws.String("arn:aws:iam::123456789012:mfa/user"),
+			DurationSeconds: aws.Int32(3600),
+			TokenCode:       aws.String("123456"),
+		}, "107e52aa1b115d02dc081b516f2961012bcecfa7"},
+	}
+	for _, tt := range tests {
+		if got := RoleCacheKey(tt.input); got != tt.want {
+			t.Errorf("RoleCacheKey(%v) = %v, want %v", aws.ToString(tt.input.RoleArn), got, tt.want)
+		}
+	}
+}
+
+// countingSTSAPI counts AssumeRole calls.
+type countingSTSAPI struct {
+	Calls     int
+	TokenCode string
+}
+
+func (m *countingSTSAPI) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	m.Calls++
+	m.TokenCode = aws.ToString(params.TokenCode)
+	return &sts.AssumeRoleOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("ASIAEXAMPLE"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      aws.Time(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)),
+		},
+		AssumedRoleUser: &types.AssumedRoleUser{AssumedRoleId: aws.String("AROAEXAMPLE:session"), Arn: aws.String("arn:aws:sts::123456789012:assumed-role/rdp/session")},
+	}, nil
+}
+
+func Test_cachedAssumeRoleClient(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := &RoleCache{Dir: t.TempDir(), Now: func() time.Time { return now }}
+	api := &countingSTSAPI{}
+	prompts := 0
+	tokenProvider := func(serialNumber string) (string, error) {
+		prompts++
+		return "123456", nil
+	}
+	options := &stscreds.AssumeRoleOptions{Client: api, RoleARN: "arn:aws:iam::123456789012:role/rdp", SerialNumber: aws.String("arn:aws:iam::123456789012:mfa/user")}
+	withRoleCache(options, cache, tokenProvider)
+	if options.Duration != time.Hour {
+		t.Errorf("Default duration must be 1 hour (%v)", options.Duration)
+	}
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(options.RoleARN),
+		RoleSessionName: aws.String("session"),
+		SerialNumber:    options.SerialNumber,
+		DurationSeconds: aws.Int32(3600),
+	}
+
+	// the first call prompts MFA code and writes the cache
+	_, err := options.Client.AssumeRole(context.Background(), input)
+	if err != nil || api.Calls != 1 || prompts != 1 || api.TokenCode != "123456" {
+		t.Fatalf("Invalid first call (calls %v, prompts %v, token %v, %v)", api.Calls, prompts, api.TokenCode, err)
+	}
+	// the default duration is not included in the key, the same as AWS CLI
+	data, err := os.ReadFile(filepath.Join(cache.Dir, RoleCacheKey(&sts.AssumeRoleInput{RoleArn: input.RoleArn, SerialNumber: input.SerialNumber})+".json"))
+	if err != nil {
+		t.Fatalf("Cache file is not written (%v)", err)
+	}
+	fields := map[string]map[string]string{}
+	json.Unmarshal(data, &fields)
+	if fields["Credentials"]["AccessKeyId"] != "ASIAEXAMPLE" || fields["Credentials"]["Expiration"] != "2024-01-01T01:00:00Z" || fields["AssumedRoleUser"]["Arn"] == "" {
+		t.Errorf("Invalid cache file\n%v", string(data))
+	}
+
+	// the second call uses the cache without prompt
+	output, err := options.Client.AssumeRole(context.Background(), input)
+	if err != nil || api.Calls != 1 || prompts != 1 || aws.ToString(output.Credentials.AccessKeyId) != "ASIAEXAMPLE" {
+		t.Errorf("Cache is not used (calls %v, prompts %v, %v)", api.Calls, prompts, err)
+	}
+
+	// the credentials expiring soon are not used
+	now = now.Add(50 * time.Minute)
+	_, err = options.Client.AssumeRole(context.Background(), input)
+	if err != nil || api.Calls != 2 || prompts != 2 {
+		t.Errorf("Expired cache is used (calls %v, prompts %v, %v)", api.Calls, prompts, err)
+	}
+}
+
+func Test_parseMFAToken(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"123456", "123456", false},
+		{" 123456\r\n", "123456", false},
+		{"", "", true},
+		{"12a456", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseMFAToken(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseMFAToken(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
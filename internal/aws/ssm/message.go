@@ -0,0 +1,157 @@
+package ssm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Session Manager data channel message types
+// ref : https://github.com/aws/session-manager-plugin/blob/mainline/src/message/clientmessage.go
+const (
+	MessageTypeInputStreamData  = "input_stream_data"
+	MessageTypeOutputStreamData = "output_stream_data"
+	MessageTypeAcknowledge      = "acknowledge"
+	MessageTypeChannelClosed    = "channel_closed"
+	MessageTypeStartPublication = "start_publication"
+	MessageTypePausePublication = "pause_publication"
+)
+
+type PayloadType uint32
+
+const (
+	PayloadTypeOutput            PayloadType = 1
+	PayloadTypeError             PayloadType = 2
+	PayloadTypeSize              PayloadType = 3
+	PayloadTypeParameter         PayloadType = 4
+	PayloadTypeHandshakeRequest  PayloadType = 5
+	PayloadTypeHandshakeResponse PayloadType = 6
+	PayloadTypeHandshakeComplete PayloadType = 7
+	PayloadTypeFlag              PayloadType = 10
+)
+
+// Flag payloads of port forwarding session
+const (
+	FlagDisconnectToPort   uint32 = 1
+	FlagTerminateSession   uint32 = 2
+	FlagConnectToPortError uint32 = 3
+)
+
+// Message flags
+const (
+	flagSyn uint64 = 1
+	flagFin uint64 = 2
+)
+
+// ClientMessage layout
+const (
+	messageTypeLength    = 32
+	messageTypeOffset    = 4
+	schemaVersionOffset  = 36
+	createdDateOffset    = 40
+	sequenceNumberOffset = 48
+	flagsOffset          = 56
+	messageIdOffset      = 64
+	payloadDigestOffset  = 80
+	payloadTypeOffset    = 112
+	payloadLengthOffset  = 116
+	payloadOffset        = 120
+)
+
+// ClientMessage is the binary message of Session Manager data channel.
+type ClientMessage struct {
+	MessageType    string
+	SchemaVersion  uint32
+	CreatedDate    uint64
+	SequenceNumber int64
+	Flags          uint64
+	MessageId      UUID
+	PayloadType    PayloadType
+	Payload        []byte
+}
+
+func newClientMessage(messageType string, sequenceNumber int64, flags uint64, payloadType PayloadType, payload []byte) *ClientMessage {
+	return &ClientMessage{
+		MessageType:    messageType,
+		SchemaVersion:  1,
+		CreatedDate:    uint64(time.Now().UnixMilli()),
+		SequenceNumber: sequenceNumber,
+		Flags:          flags,
+		MessageId:      NewUUID(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+}
+
+func (m *ClientMessage) MarshalBinary() ([]byte, error) {
+	if len(m.MessageType) > messageTypeLength {
+		return nil, fmt.Errorf("message type %v is too long", m.MessageType)
+	}
+	data := make([]byte, payloadOffset+len(m.Payload))
+	binary.BigEndian.PutUint32(data[0:], payloadLengthOffset)
+	copy(data[messageTypeOffset:], m.MessageType+strings.Repeat(" ", messageTypeLength-len(m.MessageType)))
+	binary.BigEndian.PutUint32(data[schemaVersionOffset:], m.SchemaVersion)
+	binary.BigEndian.PutUint64(data[createdDateOffset:], m.CreatedDate)
+	binary.BigEndian.PutUint64(data[sequenceNumberOffset:], uint64(m.SequenceNumber))
+	binary.BigEndian.PutUint64(data[flagsOffset:], m.Flags)
+	// UUID is serialized as least significant bits followed by most significant bits.
+	copy(data[messageIdOffset:], m.MessageId[8:])
+	copy(data[messageIdOffset+8:], m.MessageId[:8])
+	digest := sha256.Sum256(m.Payload)
+	copy(data[payloadDigestOffset:], digest[:])
+	binary.BigEndian.PutUint32(data[payloadTypeOffset:], uint32(m.PayloadType))
+	binary.BigEndian.PutUint32(data[payloadLengthOffset:], uint32(len(m.Payload)))
+	copy(data[payloadOffset:], m.Payload)
+	return data, nil
+}
+
+func (m *ClientMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < payloadOffset {
+		return errors.New("client message is too short")
+	}
+	headerLength := binary.BigEndian.Uint32(data[0:])
+	if int(headerLength)+4 > len(data) || headerLength < payloadLengthOffset {
+		return errors.New("invalid client message header length")
+	}
+	m.MessageType = strings.TrimRight(string(data[messageTypeOffset:messageTypeOffset+messageTypeLength]), " \x00")
+	m.SchemaVersion = binary.BigEndian.Uint32(data[schemaVersionOffset:])
+	m.CreatedDate = binary.BigEndian.Uint64(data[createdDateOffset:])
+	m.SequenceNumber = int64(binary.BigEndian.Uint64(data[sequenceNumberOffset:]))
+	m.Flags = binary.BigEndian.Uint64(data[flagsOffset:])
+	copy(m.MessageId[8:], data[messageIdOffset:messageIdOffset+8])
+	copy(m.MessageId[:8], data[messageIdOffset+8:messageIdOffset+16])
+	m.PayloadType = PayloadType(binary.BigEndian.Uint32(data[payloadTypeOffset:]))
+	payloadLength := binary.BigEndian.Uint32(data[headerLength:])
+	start := int(headerLength) + 4
+	if start+int(payloadLength) > len(data) {
+		return errors.New("invalid client message payload length")
+	}
+	m.Payload = data[start : start+int(payloadLength)]
+	digest := sha256.Sum256(m.Payload)
+	if !bytes.Equal(digest[:], data[payloadDigestOffset:payloadDigestOffset+32]) {
+		return errors.New("client message payload digest mismatch")
+	}
+	return nil
+}
+
+type UUID [16]byte
+
+// NewUUID returns random (version 4) UUID.
+func NewUUID() UUID {
+	var u UUID
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return fmt.Sprintf("%v-%v-%v-%v-%v", s[0:8], s[8:12], s[12:16], s[16:20], s[20:32])
+}
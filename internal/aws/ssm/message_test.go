@@ -0,0 +1,68 @@
+package ssm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+func Test_ClientMessage(t *testing.T) {
+	m := newClientMessage(MessageTypeInputStreamData, 3, flagSyn, PayloadTypeOutput, []byte("hello"))
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal("Failed to marshal message")
+	}
+
+	// header layout
+	if len(data) != payloadOffset+5 {
+		t.Errorf("Invalid message length %v", len(data))
+	}
+	if binary.BigEndian.Uint32(data[0:]) != 116 {
+		t.Errorf("Invalid header length %v", binary.BigEndian.Uint32(data[0:]))
+	}
+	if string(data[messageTypeOffset:messageTypeOffset+messageTypeLength]) != "input_stream_data               " {
+		t.Errorf("Invalid message type %q", data[messageTypeOffset:messageTypeOffset+messageTypeLength])
+	}
+	if binary.BigEndian.Uint64(data[sequenceNumberOffset:]) != 3 {
+		t.Error("Invalid sequence number")
+	}
+	if !bytes.Equal(data[messageIdOffset:messageIdOffset+8], m.MessageId[8:]) || !bytes.Equal(data[messageIdOffset+8:messageIdOffset+16], m.MessageId[:8]) {
+		t.Error("Invalid message id layout")
+	}
+	digest := sha256.Sum256([]byte("hello"))
+	if !bytes.Equal(data[payloadDigestOffset:payloadDigestOffset+32], digest[:]) {
+		t.Error("Invalid payload digest")
+	}
+
+	// roundtrip
+	actual := &ClientMessage{}
+	if err := actual.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Failed to unmarshal message (%v)", err)
+	}
+	if actual.MessageType != m.MessageType || actual.SequenceNumber != m.SequenceNumber || actual.Flags != m.Flags ||
+		actual.MessageId != m.MessageId || actual.PayloadType != m.PayloadType || string(actual.Payload) != "hello" {
+		t.Errorf("Invalid unmarshaled message %+v", actual)
+	}
+
+	// digest mismatch
+	data[len(data)-1] = 'x'
+	if err := actual.UnmarshalBinary(data); err == nil {
+		t.Error("Digest mismatch must be error")
+	}
+
+	// too short
+	if err := actual.UnmarshalBinary(data[:100]); err == nil {
+		t.Error("Too short message must be error")
+	}
+}
+
+func Test_UUID(t *testing.T) {
+	u := UUID{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	if u.String() != "12345678-9abc-def0-0123-456789abcdef" {
+		t.Errorf("Invalid UUID string %v", u.String())
+	}
+	if NewUUID() == NewUUID() {
+		t.Error("UUID must be random")
+	}
+}
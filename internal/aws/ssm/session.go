@@ -0,0 +1,448 @@
+package ssm
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/gorilla/websocket"
+	"github.com/stknohg/ec2rdp/internal/aws/endpoint"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
+)
+
+const (
+	// Agent uses basic (non-multiplexed) port forwarding for the client older than 1.1.70.
+	clientVersion = "1.1.61.0"
+	// same as session-manager-plugin
+	streamDataPayloadSize = 1024
+	pingInterval          = 5 * time.Minute
+)
+
+type openDataChannelInput struct {
+	MessageSchemaVersion string
+	RequestId            string
+	TokenValue           string
+	ClientId             string
+	ClientVersion        string
+}
+
+type acknowledgeContent struct {
+	AcknowledgedMessageType           string
+	AcknowledgedMessageId             string
+	AcknowledgedMessageSequenceNumber int64
+	IsSequentialMessage               bool
+}
+
+type handshakeRequestPayload struct {
+	AgentVersion           string
+	RequestedClientActions []requestedClientAction
+}
+
+type requestedClientAction struct {
+	ActionType       string
+	ActionParameters json.RawMessage
+}
+
+type handshakeResponsePayload struct {
+	ClientVersion          string
+	ProcessedClientActions []processedClientAction
+	Errors                 []string
+}
+
+type processedClientAction struct {
+	ActionType   string
+	ActionStatus int
+	Error        string `json:",omitempty"`
+}
+
+// Handshake action status
+const (
+	actionStatusSuccess     = 1
+	actionStatusUnsupported = 3
+)
+
+// PortForwardingSession relays local TCP connections via Session Manager data channel.
+// It gets ready when the handshake with the agent completes.
+// ref : https://github.com/aws/session-manager-plugin/tree/mainline/src/sessionmanagerplugin/session/portsession
+type PortForwardingSession struct {
+	tunnel.State
+	API          SSMAPI
+	Target       string
+	DocumentName string
+	Parameters   map[string][]string
+	Reason       string
+	LocalHost    string
+	LocalPort    int
+	StreamURL    string // overrides the scheme, host and base path of the stream URL (custom ssmmessages endpoint)
+	SessionId    string
+
+	ws        *websocket.Conn
+	writeMu   sync.Mutex
+	sequence  int64
+	listener  net.Listener
+	connMu    sync.Mutex
+	conn      net.Conn
+	closed    bool
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewPortForwardingSession(api SSMAPI, target string, documentName string, parameters map[string][]string, reason string, localPort int) *PortForwardingSession {
+	return &PortForwardingSession{
+		API:          api,
+		Target:       target,
+		DocumentName: documentName,
+		Parameters:   parameters,
+		Reason:       reason,
+		LocalHost:    "localhost",
+		LocalPort:    localPort,
+	}
+}
+
+// Open starts the session and relays connections of the local port without session-manager-plugin.
+func (s *PortForwardingSession) Open(ctx context.Context) error {
+	if s.Target == "" {
+		return fmt.Errorf("no target specified")
+	}
+
+	// start session
+	input := &ssm.StartSessionInput{
+		Target:       &s.Target,
+		DocumentName: &s.DocumentName,
+		Parameters:   s.Parameters,
+		Reason:       &s.Reason,
+	}
+	result, err := s.API.StartSession(ctx, input)
+	if err != nil {
+		return err
+	}
+	if result.SessionId == nil || result.StreamUrl == nil || result.TokenValue == nil {
+		return errors.New("invalid StartSession response")
+	}
+	s.SessionId = *result.SessionId
+
+	// open data channel and listen the local port
+	streamUrl := *result.StreamUrl
+	if s.StreamURL != "" {
+		streamUrl, err = overrideStreamURL(streamUrl, s.StreamURL)
+		if err != nil {
+			TerminateSSMSession(s.API, context.Background(), s.SessionId)
+			return err
+		}
+	}
+	err = s.openDataChannel(ctx, streamUrl, *result.TokenValue)
+	if err != nil {
+		TerminateSSMSession(s.API, context.Background(), s.SessionId)
+		return err
+	}
+	err = s.listen(s.LocalHost, s.LocalPort)
+	if err != nil {
+		s.Close()
+		return err
+	}
+	return nil
+}
+
+// overrideStreamURL replaces the scheme and host of the stream URL, and prepends the base path.
+func overrideStreamURL(streamUrl string, endpointURL string) (string, error) {
+	u, err := url.Parse(streamUrl)
+	if err != nil {
+		return "", err
+	}
+	e, err := url.Parse(endpoint.WebSocketURL(endpointURL))
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = e.Scheme
+	u.Host = e.Host
+	u.Path = strings.TrimSuffix(e.Path, "/") + u.Path
+	return u.String(), nil
+}
+
+// openDataChannel connects to the stream URL and starts reading messages.
+func (s *PortForwardingSession) openDataChannel(ctx context.Context, streamUrl string, token string) error {
+	dialer := &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: 30 * time.Second}
+	ws, resp, err := dialer.DialContext(ctx, streamUrl, nil)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to open data channel (status=%v)", resp.Status)
+		}
+		return err
+	}
+	s.ws = ws
+	input := openDataChannelInput{
+		MessageSchemaVersion: "1.0",
+		RequestId:            NewUUID().String(),
+		TokenValue:           token,
+		ClientId:             NewUUID().String(),
+		ClientVersion:        clientVersion,
+	}
+	data, _ := json.Marshal(input)
+	err = s.writeMessage(websocket.TextMessage, data)
+	if err != nil {
+		ws.Close()
+		return err
+	}
+	s.wg.Add(2)
+	go s.readLoop()
+	go s.pingLoop()
+	return nil
+}
+
+// listen starts listening the local port and relaying connections in background.
+func (s *PortForwardingSession) listen(localHost string, localPort int) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(localHost, strconv.Itoa(localPort)))
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return nil
+}
+
+// Close closes the data channel and terminates the session.
+func (s *PortForwardingSession) Close() error {
+	if s.SessionId == "" {
+		return errors.New("session is not started")
+	}
+	s.closeOnce.Do(func() {
+		s.SetDone(nil)
+		if s.listener != nil {
+			s.listener.Close()
+		}
+		s.closeConn()
+		if s.ws != nil {
+			s.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			s.ws.Close()
+		}
+		s.wg.Wait()
+	})
+	return TerminateSSMSession(s.API, context.Background(), s.SessionId)
+}
+
+func (s *PortForwardingSession) String() string {
+	return fmt.Sprintf("SSM session %v", s.SessionId)
+}
+
+func (s *PortForwardingSession) writeMessage(messageType int, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.ws.WriteMessage(messageType, data)
+}
+
+func (s *PortForwardingSession) sendMessage(m *ClientMessage) error {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.writeMessage(websocket.BinaryMessage, data)
+}
+
+func (s *PortForwardingSession) sendInputData(payloadType PayloadType, payload []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	flags := uint64(0)
+	if s.sequence == 0 {
+		flags = flagSyn
+	}
+	data, err := newClientMessage(MessageTypeInputStreamData, s.sequence, flags, payloadType, payload).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	err = s.ws.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		return err
+	}
+	s.sequence++
+	return nil
+}
+
+func (s *PortForwardingSession) sendFlag(flag uint32) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, flag)
+	return s.sendInputData(PayloadTypeFlag, payload)
+}
+
+func (s *PortForwardingSession) sendAcknowledge(m *ClientMessage) error {
+	content := acknowledgeContent{
+		AcknowledgedMessageType:           m.MessageType,
+		AcknowledgedMessageId:             m.MessageId.String(),
+		AcknowledgedMessageSequenceNumber: m.SequenceNumber,
+		IsSequentialMessage:               true,
+	}
+	payload, _ := json.Marshal(content)
+	return s.sendMessage(newClientMessage(MessageTypeAcknowledge, 0, flagSyn|flagFin, 0, payload))
+}
+
+func (s *PortForwardingSession) readLoop() {
+	defer s.wg.Done()
+	defer s.closeConn()
+	// output messages are processed in order of sequence number.
+	expected := int64(0)
+	pending := map[int64]*ClientMessage{}
+	for {
+		messageType, data, err := s.ws.ReadMessage()
+		if err != nil {
+			s.SetDone(fmt.Errorf("data channel is closed: %w", err))
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		m := &ClientMessage{}
+		if err := m.UnmarshalBinary(data); err != nil {
+			continue
+		}
+		switch m.MessageType {
+		case MessageTypeOutputStreamData:
+			s.sendAcknowledge(m)
+			if m.SequenceNumber < expected {
+				// already processed
+				continue
+			}
+			pending[m.SequenceNumber] = m
+			for {
+				next, ok := pending[expected]
+				if !ok {
+					break
+				}
+				delete(pending, expected)
+				s.processOutput(next)
+				expected++
+			}
+		case MessageTypeChannelClosed:
+			s.SetDone(errors.New("data channel is closed by the agent"))
+			return
+		default:
+			// acknowledge and publication control messages are not needed because messages are never resent.
+		}
+	}
+}
+
+func (s *PortForwardingSession) pingLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.Done():
+			return
+		case <-ticker.C:
+			s.ws.WriteControl(websocket.PingMessage, []byte("keepalive"), time.Now().Add(10*time.Second))
+		}
+	}
+}
+
+func (s *PortForwardingSession) processOutput(m *ClientMessage) {
+	switch m.PayloadType {
+	case PayloadTypeOutput:
+		s.connMu.Lock()
+		conn := s.conn
+		s.connMu.Unlock()
+		if conn == nil {
+			return
+		}
+		if _, err := conn.Write(m.Payload); err != nil {
+			conn.Close()
+		}
+	case PayloadTypeHandshakeRequest:
+		s.processHandshakeRequest(m.Payload)
+	case PayloadTypeHandshakeComplete:
+		s.SetReady()
+	}
+}
+
+func (s *PortForwardingSession) processHandshakeRequest(payload []byte) error {
+	request := handshakeRequestPayload{}
+	err := json.Unmarshal(payload, &request)
+	if err != nil {
+		return err
+	}
+	response := handshakeResponsePayload{ClientVersion: clientVersion, ProcessedClientActions: []processedClientAction{}, Errors: []string{}}
+	for _, action := range request.RequestedClientActions {
+		switch action.ActionType {
+		case "SessionType":
+			response.ProcessedClientActions = append(response.ProcessedClientActions, processedClientAction{ActionType: action.ActionType, ActionStatus: actionStatusSuccess})
+		default:
+			// KMS encryption is not supported.
+			message := fmt.Sprintf("%v is not supported", action.ActionType)
+			response.ProcessedClientActions = append(response.ProcessedClientActions, processedClientAction{ActionType: action.ActionType, ActionStatus: actionStatusUnsupported, Error: message})
+			response.Errors = append(response.Errors, message)
+		}
+	}
+	data, _ := json.Marshal(response)
+	return s.sendInputData(PayloadTypeHandshakeResponse, data)
+}
+
+func (s *PortForwardingSession) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		// basic port forwarding relays one connection at a time.
+		s.relay(conn)
+	}
+}
+
+func (s *PortForwardingSession) relay(conn net.Conn) {
+	defer conn.Close()
+	select {
+	case <-s.Ready():
+	case <-s.Done():
+		return
+	}
+	if !s.setConn(conn) {
+		return
+	}
+	buf := make([]byte, streamDataPayloadSize)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if werr := s.sendInputData(PayloadTypeOutput, buf[:n]); werr != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if s.setConn(nil) {
+		// agent closes the connection to the remote port too.
+		s.sendFlag(FlagDisconnectToPort)
+	}
+}
+
+// setConn replaces the relaying connection. It returns false after the session is closed.
+func (s *PortForwardingSession) setConn(conn net.Conn) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conn = conn
+	return true
+}
+
+// closeConn closes the relaying connection and prevents relaying new connections.
+func (s *PortForwardingSession) closeConn() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
@@ -0,0 +1,211 @@
+package ssm
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/gorilla/websocket"
+	"github.com/stknohg/ec2rdp/internal/tunnel"
+)
+
+// newAgentServer starts the WebSocket stand-in of Session Manager data channel.
+// It echoes received stream data in reverse sequence order to test reordering.
+func newAgentServer(t *testing.T, handshake chan<- handshakeResponsePayload, flags chan<- uint32) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		// open data channel
+		mt, data, err := ws.ReadMessage()
+		if err != nil || mt != websocket.TextMessage {
+			return
+		}
+		input := openDataChannelInput{}
+		if err := json.Unmarshal(data, &input); err != nil || input.TokenValue != "token" {
+			return
+		}
+		sequence := int64(0)
+		send := func(payloadType PayloadType, payload []byte) error {
+			data, _ := newClientMessage(MessageTypeOutputStreamData, sequence, 0, payloadType, payload).MarshalBinary()
+			sequence++
+			return ws.WriteMessage(websocket.BinaryMessage, data)
+		}
+		request, _ := json.Marshal(handshakeRequestPayload{
+			AgentVersion: "3.3.0.0",
+			RequestedClientActions: []requestedClientAction{
+				{ActionType: "SessionType", ActionParameters: json.RawMessage(`{"SessionType":"Port"}`)},
+			},
+		})
+		if err := send(PayloadTypeHandshakeRequest, request); err != nil {
+			return
+		}
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			m := &ClientMessage{}
+			if err := m.UnmarshalBinary(data); err != nil || m.MessageType != MessageTypeInputStreamData {
+				continue
+			}
+			switch m.PayloadType {
+			case PayloadTypeHandshakeResponse:
+				response := handshakeResponsePayload{}
+				json.Unmarshal(m.Payload, &response)
+				handshake <- response
+				send(PayloadTypeHandshakeComplete, []byte(`{"HandshakeTimeToComplete":1000000,"CustomerMessage":""}`))
+			case PayloadTypeOutput:
+				// send the second half before the first half
+				half := len(m.Payload) / 2
+				first, _ := newClientMessage(MessageTypeOutputStreamData, sequence, 0, PayloadTypeOutput, m.Payload[:half]).MarshalBinary()
+				second, _ := newClientMessage(MessageTypeOutputStreamData, sequence+1, 0, PayloadTypeOutput, m.Payload[half:]).MarshalBinary()
+				sequence += 2
+				ws.WriteMessage(websocket.BinaryMessage, second)
+				ws.WriteMessage(websocket.BinaryMessage, first)
+			case PayloadTypeFlag:
+				flags <- binary.BigEndian.Uint32(m.Payload)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getFreePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func Test_PortForwardingSession(t *testing.T) {
+	handshake := make(chan handshakeResponsePayload, 1)
+	flags := make(chan uint32, 1)
+	server := newAgentServer(t, handshake, flags)
+	mock := &MockAPI{
+		StartSessionOutput: &ssm.StartSessionOutput{
+			SessionId:  toPtr("ec2rdp-1234567890"),
+			StreamUrl:  toPtr(strings.Replace(server.URL, "http://", "ws://", 1)),
+			TokenValue: toPtr("token"),
+		},
+		TerminateSessionOutput: &ssm.TerminateSessionOutput{},
+		Error:                  nil,
+	}
+	localPort := getFreePort(t)
+	session := NewSSMSessionPortForward(mock, "i-1234567890", 3389, localPort, "test")
+	if err := tunnel.Start(context.Background(), session, 5*time.Second); err != nil {
+		t.Fatalf("Failed to start session (%v)", err)
+	}
+	defer session.Close()
+	if session.SessionId != "ec2rdp-1234567890" {
+		t.Errorf("Invalid session id %v", session.SessionId)
+	}
+
+	// handshake
+	response := <-handshake
+	if len(response.ProcessedClientActions) != 1 || response.ProcessedClientActions[0].ActionStatus != actionStatusSuccess {
+		t.Errorf("Invalid handshake response %+v", response)
+	}
+
+	// relay data in order of sequence number
+	conn, err := net.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(localPort)))
+	if err != nil {
+		t.Fatal("Failed to connect local port")
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("hello world")); err != nil {
+		t.Fatal("Failed to write data")
+	}
+	buf := make([]byte, 11)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello world" {
+		t.Errorf("Invalid relayed data %q", buf)
+	}
+
+	// disconnect flag is sent after the connection is closed
+	conn.Close()
+	select {
+	case flag := <-flags:
+		if flag != FlagDisconnectToPort {
+			t.Errorf("Invalid flag %v", flag)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("DisconnectToPort flag is not sent")
+	}
+
+	// close session
+	if err := session.Close(); err != nil {
+		t.Error("Failed to close session")
+	}
+	select {
+	case <-session.Done():
+		if session.Err() != nil {
+			t.Errorf("Session must be closed normally (%v)", session.Err())
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Data channel must be closed")
+	}
+	if _, err := net.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(localPort))); err == nil {
+		t.Error("Local port must be closed")
+	}
+}
+
+func Test_PortForwardingSession_Error(t *testing.T) {
+	// when data channel is not available
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	mock := &MockAPI{
+		StartSessionOutput: &ssm.StartSessionOutput{
+			SessionId:  toPtr("ec2rdp-1234567890"),
+			StreamUrl:  toPtr(strings.Replace(server.URL, "http://", "ws://", 1)),
+			TokenValue: toPtr("token"),
+		},
+		TerminateSessionOutput: &ssm.TerminateSessionOutput{},
+		Error:                  nil,
+	}
+	session := NewSSMSessionPortForward(mock, "i-1234567890", 3389, getFreePort(t), "test")
+	if err := session.Open(context.Background()); err == nil {
+		t.Error("Failed to detect data channel error")
+	}
+
+	// when target is empty
+	session = NewSSMSessionPortForward(mock, "", 3389, getFreePort(t), "test")
+	if err := session.Open(context.Background()); err == nil {
+		t.Error("Failed to detect empty target")
+	}
+}
+
+func toPtr(s string) *string {
+	return &s
+}
+
+func Test_overrideStreamURL(t *testing.T) {
+	streamUrl := "wss://ssmmessages.ap-northeast-1.amazonaws.com/v1/data-channel/session-id?role=publish_subscribe"
+	tests := []struct {
+		endpointURL string
+		want        string
+	}{
+		{"https://vpce-1.ssmmessages.ap-northeast-1.vpce.amazonaws.com", "wss://vpce-1.ssmmessages.ap-northeast-1.vpce.amazonaws.com/v1/data-channel/session-id?role=publish_subscribe"},
+		{"http://localhost:4566/ssmmessages/", "ws://localhost:4566/ssmmessages/v1/data-channel/session-id?role=publish_subscribe"},
+	}
+	for _, tt := range tests {
+		got, err := overrideStreamURL(streamUrl, tt.endpointURL)
+		if err != nil || got != tt.want {
+			t.Errorf("overrideStreamURL(%v) = %v, want %v (%v)", tt.endpointURL, got, tt.want, err)
+		}
+	}
+}
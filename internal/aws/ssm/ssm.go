@@ -2,14 +2,15 @@ package ssm
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os/exec"
+	"maps"
 	"strconv"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stknohg/ec2rdp/internal/aws/endpoint"
 )
 
 type SSMAPI interface {
@@ -20,19 +21,12 @@ type SSMAPI interface {
 	TerminateSession(ctx context.Context, params *ssm.TerminateSessionInput, optFns ...func(*ssm.Options)) (*ssm.TerminateSessionOutput, error)
 }
 
-type StartSSMSessionPluginResult struct {
-	API       SSMAPI
-	SessionId string
-	ProcessId int
-}
-
-type sessionManagerPluginParameter struct {
-	Target     string
-	Parameters map[string][]string
-}
-
 func NewAPI(cfg aws.Config) SSMAPI {
-	return ssm.NewFromConfig(cfg)
+	return ssm.NewFromConfig(cfg, func(o *ssm.Options) {
+		if u, ok := endpoint.Resolve(cfg, endpoint.SSM); ok {
+			o.BaseEndpoint = aws.String(u)
+		}
+	})
 }
 
 func IsInstanceOnline(api SSMAPI, ctx context.Context, instanceId string) (bool, error) {
@@ -55,58 +49,84 @@ func IsInstanceOnline(api SSMAPI, ctx context.Context, instanceId string) (bool,
 	return false, fmt.Errorf("instance %v is not online. (SSM PingStatus : %v)", instanceId, status)
 }
 
-func StartSSMSessionPortForward(api SSMAPI, ctx context.Context, instanceId string, port int, localPort int, reason string, region string, profile string) (*StartSSMSessionPluginResult, error) {
-	return StartSSMSessionWithPlugin(
-		api,
-		ctx,
-		instanceId,
-		"AWS-StartPortForwardingSession",
-		map[string][]string{"portNumber": {strconv.Itoa(port)}, "localPortNumber": {strconv.Itoa(localPort)}},
-		reason,
-		region,
-		profile)
-}
+// the max number of values in the InstanceIds filter
+const maxInstanceIdsFilterValues = 50
+
+// the max number of concurrent DescribeInstanceInformation calls
+const maxConcurrentDescribe = 4
 
-func StartSSMSessionWithPlugin(api SSMAPI, ctx context.Context, target string, documentName string, parameters map[string][]string, reason string, region string, profile string) (*StartSSMSessionPluginResult, error) {
-	if target == "" {
-		return &StartSSMSessionPluginResult{}, fmt.Errorf("no target specified")
+// GetPingStatuses returns SSM PingStatus of the instances. Unmanaged instances are not contained.
+// The instance IDs are divided into batches and described concurrently.
+func GetPingStatuses(api SSMAPI, ctx context.Context, instanceIds []string) (map[string]types.PingStatus, error) {
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	statuses := map[string]types.PingStatus{}
+	semaphore := make(chan struct{}, maxConcurrentDescribe)
+	for start := 0; start < len(instanceIds); start += maxInstanceIdsFilterValues {
+		batch := instanceIds[start:min(start+maxInstanceIdsFilterValues, len(instanceIds))]
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer func() { <-semaphore; wg.Done() }()
+			result, err := describePingStatuses(api, ctx, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			maps.Copy(statuses, result)
+		}()
 	}
-	if region == "" {
-		return &StartSSMSessionPluginResult{}, fmt.Errorf("no region name specified")
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
 	}
+	return statuses, nil
+}
 
-	// start session
-	input := &ssm.StartSessionInput{
-		Target:       &target,
-		DocumentName: &documentName,
-		Parameters:   parameters,
-		Reason:       &reason,
+func describePingStatuses(api SSMAPI, ctx context.Context, instanceIds []string) (map[string]types.PingStatus, error) {
+	input := &ssm.DescribeInstanceInformationInput{
+		Filters: []types.InstanceInformationStringFilter{
+			{Key: aws.String("InstanceIds"), Values: instanceIds},
+		},
 	}
-	result, err := api.StartSession(ctx, input)
-	if err != nil {
-		return &StartSSMSessionPluginResult{}, err
+	statuses := map[string]types.PingStatus{}
+	paginator := ssm.NewDescribeInstanceInformationPaginator(api, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range output.InstanceInformationList {
+			statuses[aws.ToString(i.InstanceId)] = i.PingStatus
+		}
 	}
+	return statuses, nil
+}
 
-	// start session manager plugin
-	// arg1
-	sessionJson, _ := json.Marshal(result)
-	arg1 := string(sessionJson)
-	// arg1
-	arg2 := region
-	// arg3
-	arg3 := "StartSession"
-	// arg4
-	arg4 := profile
-	// arg5
-	pluginParameter := &sessionManagerPluginParameter{Target: target, Parameters: input.Parameters}
-	parameterJson, _ := json.Marshal(pluginParameter)
-	arg5 := string(parameterJson)
-	// arg6
-	arg6 := fmt.Sprintf("https://ssm.%v.amazonaws.com", region)
-	// start process
-	cmd := exec.Command("session-manager-plugin", arg1, arg2, arg3, arg4, arg5, arg6)
-	err = cmd.Start()
-	return &StartSSMSessionPluginResult{API: api, SessionId: *result.SessionId, ProcessId: cmd.Process.Pid}, err
+func NewSSMSessionPortForward(api SSMAPI, instanceId string, port int, localPort int, reason string) *PortForwardingSession {
+	return NewPortForwardingSession(
+		api,
+		instanceId,
+		"AWS-StartPortForwardingSession",
+		map[string][]string{"portNumber": {strconv.Itoa(port)}, "localPortNumber": {strconv.Itoa(localPort)}},
+		reason,
+		localPort)
+}
+
+// NewSSMSessionPortForwardToRemoteHost forwards the port of the remote host through the instance.
+func NewSSMSessionPortForwardToRemoteHost(api SSMAPI, instanceId string, host string, port int, localPort int, reason string) *PortForwardingSession {
+	return NewPortForwardingSession(
+		api,
+		instanceId,
+		"AWS-StartPortForwardingSessionToRemoteHost",
+		map[string][]string{"host": {host}, "portNumber": {strconv.Itoa(port)}, "localPortNumber": {strconv.Itoa(localPort)}},
+		reason,
+		localPort)
 }
 
 func TerminateSSMSession(api SSMAPI, ctx context.Context, sessionId string) error {
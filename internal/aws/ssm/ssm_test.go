@@ -2,8 +2,11 @@ package ssm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 )
@@ -75,3 +78,39 @@ func Test_IsInstanceOnline(t *testing.T) {
 		t.Error("Instance status is Online")
 	}
 }
+
+func Test_GetPingStatuses(t *testing.T) {
+	var mock = &MockAPI{
+		DescribeInstanceInformationOutput: &ssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []types.InstanceInformation{
+				{InstanceId: aws.String("i-01234567890abcdef"), PingStatus: types.PingStatusOnline},
+				{InstanceId: aws.String("i-0fedcba9876543210"), PingStatus: types.PingStatusConnectionLost},
+			},
+		},
+		Error: nil,
+	}
+	// more than one batch
+	instanceIds := []string{}
+	for i := range 120 {
+		instanceIds = append(instanceIds, fmt.Sprintf("i-%017x", i))
+	}
+	result, err := GetPingStatuses(mock, context.TODO(), instanceIds)
+	if err != nil {
+		t.Errorf("Failed to get ping statuses (%v)", err)
+	}
+	if result["i-01234567890abcdef"] != types.PingStatusOnline || result["i-0fedcba9876543210"] != types.PingStatusConnectionLost || len(result) != 2 {
+		t.Errorf("Invalid ping statuses %v", result)
+	}
+
+	// no instance
+	result, err = GetPingStatuses(&MockAPI{Error: errors.New("must not be called")}, context.TODO(), []string{})
+	if err != nil || len(result) != 0 {
+		t.Errorf("Invalid ping statuses %v (%v)", result, err)
+	}
+
+	// error
+	_, err = GetPingStatuses(&MockAPI{Error: errors.New("access denied")}, context.TODO(), instanceIds)
+	if err == nil {
+		t.Error("Failed to detect error")
+	}
+}
@@ -0,0 +1,308 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+const (
+	clientName         = "ec2rdp"
+	clientType         = "public"
+	deviceCodeGrant    = "urn:ietf:params:oauth:grant-type:device_code"
+	defaultScope       = "sso:account:access"
+	slowDownDelta      = 5 * time.Second
+	tokenExpiryMargin  = time.Minute
+	defaultPollSeconds = 5
+)
+
+type OIDCAPI interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// NewAPI returns the SSO OIDC client. The OIDC APIs are called without credentials.
+func NewAPI(region string) OIDCAPI {
+	return ssooidc.New(ssooidc.Options{Region: region})
+}
+
+// for testing
+var newAPI = NewAPI
+
+// for testing
+var sleep = func(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Session is the IAM Identity Center settings of the profile.
+type Session struct {
+	Name     string // sso-session name, empty for the legacy profile
+	StartURL string
+	Region   string
+}
+
+// CacheKey returns the key of the token cache, the same as AWS CLI.
+func (s Session) CacheKey() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.StartURL
+}
+
+// GetSession returns the IAM Identity Center settings of the profile (or its source profile).
+// It returns nil when the profile does not use IAM Identity Center,
+// or the profile is not specified and the SDK uses the credentials of the environment variables instead.
+func GetSession(ctx context.Context, profileName string) (*Session, error) {
+	if profileName == "" && hasEnvCredentials() {
+		return nil, nil
+	}
+	if profileName == "" {
+		profileName = os.Getenv("AWS_PROFILE")
+	}
+	if profileName == "" {
+		profileName = config.DefaultSharedConfigProfile
+	}
+	sc, err := config.LoadSharedConfigProfile(ctx, profileName, func(o *config.LoadSharedConfigOptions) {
+		// LoadSharedConfigProfile does not respect AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE environment variables
+		if v := os.Getenv("AWS_CONFIG_FILE"); v != "" {
+			o.ConfigFiles = []string{v}
+		}
+		if v := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); v != "" {
+			o.CredentialsFiles = []string{v}
+		}
+	})
+	if err != nil {
+		var notExist config.SharedConfigProfileNotExistError
+		if errors.As(err, &notExist) {
+			// the profile is not used, or the error is reported by config.LoadDefaultConfig
+			return nil, nil
+		}
+		return nil, err
+	}
+	for p := &sc; p != nil; p = p.Source {
+		if p.SSOSession != nil {
+			return &Session{Name: p.SSOSession.Name, StartURL: p.SSOSession.SSOStartURL, Region: p.SSOSession.SSORegion}, nil
+		}
+		if p.SSOStartURL != "" {
+			return &Session{StartURL: p.SSOStartURL, Region: p.SSORegion}, nil
+		}
+	}
+	return nil, nil
+}
+
+// hasEnvCredentials reports whether the credentials are set in the environment variables,
+// which take precedence over AWS_PROFILE and the default profile.
+func hasEnvCredentials() bool {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" && os.Getenv("AWS_SECRET_ACCESS_KEY") != "" {
+		return true
+	}
+	return os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != ""
+}
+
+// Token is the access token cache compatible with AWS CLI (~/.aws/sso/cache/*.json).
+type Token struct {
+	StartURL              string `json:"startUrl"`
+	Region                string `json:"region"`
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	ClientId              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+}
+
+// Expired reports whether the access token is expired (or will expire soon).
+func (t Token) Expired(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, t.ExpiresAt)
+	return t.AccessToken == "" || err != nil || !now.Add(tokenExpiryMargin).Before(expiresAt)
+}
+
+// Registered reports whether the client registration can be reused.
+func (t Token) Registered(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, t.RegistrationExpiresAt)
+	return t.ClientId != "" && t.ClientSecret != "" && err == nil && now.Add(tokenExpiryMargin).Before(expiresAt)
+}
+
+// CachedTokenPath returns the token cache path of the session.
+func CachedTokenPath(s Session) (string, error) {
+	return ssocreds.StandardCachedTokenFilepath(s.CacheKey())
+}
+
+// ReadToken reads the token cache.
+func ReadToken(path string) (Token, error) {
+	token := Token{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal(data, &token)
+	return token, err
+}
+
+// WriteToken writes the token cache, which is readable only by the user.
+func WriteToken(path string, token Token) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Login is the OIDC device authorization flow of IAM Identity Center.
+type Login struct {
+	API         OIDCAPI
+	Session     Session
+	Out         io.Writer
+	OpenBrowser func(url string) error
+	Now         func() time.Time
+}
+
+// Run authorizes the device and returns the new token. The client registration of cached token is reused if possible.
+func (l *Login) Run(ctx context.Context, cached Token) (Token, error) {
+	now := l.now()
+	token := Token{StartURL: l.Session.StartURL, Region: l.Session.Region}
+	if cached.Registered(now) {
+		token.ClientId, token.ClientSecret, token.RegistrationExpiresAt = cached.ClientId, cached.ClientSecret, cached.RegistrationExpiresAt
+	} else {
+		input := &ssooidc.RegisterClientInput{ClientName: aws.String(clientName), ClientType: aws.String(clientType)}
+		if l.Session.Name != "" {
+			input.Scopes = []string{defaultScope}
+		}
+		registered, err := l.API.RegisterClient(ctx, input)
+		if err != nil {
+			return Token{}, fmt.Errorf("failed to register client, %w", err)
+		}
+		token.ClientId = aws.ToString(registered.ClientId)
+		token.ClientSecret = aws.ToString(registered.ClientSecret)
+		token.RegistrationExpiresAt = time.Unix(registered.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+
+	device, err := l.API.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     aws.String(token.ClientId),
+		ClientSecret: aws.String(token.ClientSecret),
+		StartUrl:     aws.String(l.Session.StartURL),
+	})
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to start device authorization, %w", err)
+	}
+	url := aws.ToString(device.VerificationUriComplete)
+	fmt.Fprintf(l.Out, "Open the following URL to login to IAM Identity Center (%v)\n", l.Session.StartURL)
+	fmt.Fprintf(l.Out, "  %v\n", url)
+	fmt.Fprintf(l.Out, "and confirm the code: %v\n", aws.ToString(device.UserCode))
+	if l.OpenBrowser != nil {
+		// the URL is printed above anyway
+		l.OpenBrowser(url)
+	}
+
+	interval := time.Duration(device.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollSeconds * time.Second
+	}
+	deadline := now.Add(time.Duration(device.ExpiresIn) * time.Second)
+	for {
+		err = sleep(ctx, interval)
+		if err != nil {
+			return Token{}, err
+		}
+		created, err := l.API.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     aws.String(token.ClientId),
+			ClientSecret: aws.String(token.ClientSecret),
+			GrantType:    aws.String(deviceCodeGrant),
+			DeviceCode:   device.DeviceCode,
+		})
+		var pending *types.AuthorizationPendingException
+		var slowDown *types.SlowDownException
+		switch {
+		case err == nil:
+			token.AccessToken = aws.ToString(created.AccessToken)
+			token.RefreshToken = aws.ToString(created.RefreshToken)
+			token.ExpiresAt = l.now().Add(time.Duration(created.ExpiresIn) * time.Second).UTC().Format(time.RFC3339)
+			fmt.Fprintln(l.Out, "Successfully logged in to IAM Identity Center")
+			return token, nil
+		case errors.As(err, &slowDown):
+			interval += slowDownDelta
+		case errors.As(err, &pending):
+		default:
+			return Token{}, fmt.Errorf("failed to create token, %w", err)
+		}
+		if device.ExpiresIn > 0 && l.now().After(deadline) {
+			return Token{}, errors.New("device authorization is expired")
+		}
+	}
+}
+
+func (l *Login) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+// EnsureToken runs the device authorization flow when the profile uses IAM Identity Center and its token is expired or missing.
+// The expired token is refreshed with its refresh token first.
+func EnsureToken(ctx context.Context, profileName string, out io.Writer) error {
+	session, err := GetSession(ctx, profileName)
+	if err != nil || session == nil {
+		return err
+	}
+	path, err := CachedTokenPath(*session)
+	if err != nil {
+		return err
+	}
+	cached, err := ReadToken(path)
+	if err == nil && !cached.Expired(time.Now()) {
+		return nil
+	}
+	api := newAPI(session.Region)
+	if err == nil && cached.RefreshToken != "" {
+		// refresh the token by the SDK without the device authorization, the same as AWS CLI
+		refreshed, err := ssocreds.NewSSOTokenProvider(api, path).RetrieveBearerToken(ctx)
+		if err == nil && refreshed.Expires.After(time.Now().Add(tokenExpiryMargin)) {
+			return nil
+		}
+	}
+	fmt.Fprintln(out, "IAM Identity Center token is expired or missing")
+	login := &Login{API: api, Session: *session, Out: out, OpenBrowser: OpenBrowser}
+	token, err := login.Run(ctx, cached)
+	if err != nil {
+		return fmt.Errorf("failed to login to IAM Identity Center, %w", err)
+	}
+	return WriteToken(path, token)
+}
+
+// OpenBrowser opens the URL with the default browser.
+func OpenBrowser(url string) error {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	case "darwin":
+		return exec.Command("open", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
@@ -0,0 +1,57 @@
+package sts
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stknohg/ec2rdp/internal/aws/endpoint"
+)
+
+type STSAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+func NewAPI(cfg aws.Config) STSAPI {
+	return NewClient(cfg)
+}
+
+// NewClient returns the STS client with the custom endpoint URL.
+func NewClient(cfg aws.Config) *sts.Client {
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if u, ok := endpoint.Resolve(cfg, endpoint.STS); ok {
+			o.BaseEndpoint = aws.String(u)
+		}
+	})
+}
+
+// Identity is the caller identity of the credentials.
+type Identity struct {
+	Account string
+	Arn     string
+}
+
+// Role returns the role name of the assumed role, or the resource of ARN (e.g. user/name) for other identities.
+func (i Identity) Role() string {
+	parts := strings.SplitN(i.Arn, ":", 6)
+	if len(parts) < 6 {
+		return i.Arn
+	}
+	resource := parts[5]
+	if name, found := strings.CutPrefix(resource, "assumed-role/"); found {
+		// assumed-role/ROLE_NAME/SESSION_NAME
+		role, _, _ := strings.Cut(name, "/")
+		return role
+	}
+	return resource
+}
+
+// GetCallerIdentity returns the caller identity, and verifies the credentials.
+func GetCallerIdentity(api STSAPI, ctx context.Context) (Identity, error) {
+	result, err := api.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Account: aws.ToString(result.Account), Arn: aws.ToString(result.Arn)}, nil
+}
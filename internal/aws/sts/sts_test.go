@@ -0,0 +1,56 @@
+package sts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type MockAPI struct {
+	GetCallerIdentityOutput *sts.GetCallerIdentityOutput
+	Error                   error
+}
+
+func (m *MockAPI) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return m.GetCallerIdentityOutput, m.Error
+}
+
+func Test_GetCallerIdentity(t *testing.T) {
+	api := &MockAPI{GetCallerIdentityOutput: &sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+		Arn:     aws.String("arn:aws:sts::123456789012:assumed-role/Admin/ec2rdp-1700000000"),
+	}}
+	identity, err := GetCallerIdentity(api, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Account != "123456789012" || identity.Role() != "Admin" {
+		t.Errorf("Invalid identity %+v (role %v)", identity, identity.Role())
+	}
+
+	api.Error = errors.New("api error")
+	_, err = GetCallerIdentity(api, context.Background())
+	if err == nil {
+		t.Error("Failed to return error")
+	}
+}
+
+func Test_Role(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/Admin/session", "Admin"},
+		{"arn:aws:iam::123456789012:user/alice", "user/alice"},
+		{"arn:aws:iam::123456789012:root", "root"},
+		{"invalid", "invalid"},
+	}
+	for _, tt := range tests {
+		if got := (Identity{Arn: tt.arn}).Role(); got != tt.want {
+			t.Errorf("Role() of %v = %v, want %v", tt.arn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache stores JSON values on disk until TTL expires.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+	Now func() time.Time // for testing
+}
+
+type entry struct {
+	ExpiresAt time.Time       `json:"expiresAt"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// New returns the cache in the user cache directory (e.g. ~/.cache/ec2rdp/<name>).
+func New(name string, ttl time.Duration) (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: filepath.Join(dir, "ec2rdp", name), TTL: ttl}, nil
+}
+
+// Get reads the value of the key into v, and reports whether the unexpired value is found.
+func (c *Cache) Get(key string, v any) bool {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return false
+	}
+	if !c.now().Before(e.ExpiresAt) {
+		return false
+	}
+	return json.Unmarshal(e.Value, v) == nil
+}
+
+// Set writes the value of the key.
+func (c *Cache) Set(key string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{ExpiresAt: c.now().Add(c.TTL), Value: value})
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.Dir, 0700)
+	if err != nil {
+		return err
+	}
+	// write to the temporary file and rename it not to read the partial file
+	f, err := os.CreateTemp(c.Dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(key))
+}
+
+func (c *Cache) path(key string) string {
+	hash := sha1.Sum([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (c *Cache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_Cache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &Cache{Dir: t.TempDir(), TTL: time.Minute, Now: func() time.Time { return now }}
+
+	var values []string
+	if c.Get("key", &values) {
+		t.Error("Value must not be found")
+	}
+	if err := c.Set("key", []string{"a", "b"}); err != nil {
+		t.Fatalf("Failed to set value (%v)", err)
+	}
+	if !c.Get("key", &values) || len(values) != 2 || values[1] != "b" {
+		t.Errorf("Invalid value %v", values)
+	}
+	if c.Get("other", &values) {
+		t.Error("Value of other key must not be found")
+	}
+
+	// expired
+	now = now.Add(time.Minute)
+	if c.Get("key", &values) {
+		t.Error("Expired value must not be found")
+	}
+
+	// broken file
+	os.WriteFile(c.path("broken"), []byte("{"), 0600)
+	if c.Get("broken", &values) {
+		t.Error("Broken value must not be found")
+	}
+}
@@ -1,5 +1,12 @@
 package connector
 
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
 type Connector interface {
 	IsInstalled() (bool, error)
 	PreConnect() error
@@ -13,4 +20,120 @@ type DefaultConnector struct {
 	UserName      string
 	PlainPassword string
 	WaitFor       bool
+	Display       DisplayOptions
+}
+
+// Audio playback modes
+const (
+	AudioLocal  = "local"
+	AudioRemote = "remote"
+	AudioOff    = "off"
+)
+
+// DisplayOptions is display and redirection settings translated for each client.
+type DisplayOptions struct {
+	FullScreen   bool
+	Width        int // used when FullScreen is false. 0 means client default.
+	Height       int
+	MultiMonitor bool
+	Clipboard    bool
+	Drives       bool
+	Printers     bool
+	Audio        string
+	// KeyboardLayout is Windows keyboard layout ID (e.g. 0x00000411). 0 means client default.
+	KeyboardLayout uint32
+}
+
+// clients which apply DisplayOptions.KeyboardLayout
+var keyboardLayoutClients = []string{"freerdp", "parallels", "custom"}
+
+// ValidateDisplay returns the error when the client can not apply the display options.
+func ValidateDisplay(name string, d DisplayOptions) error {
+	if d.KeyboardLayout != 0 && !slices.Contains(keyboardLayoutClients, name) {
+		return fmt.Errorf("keyboard layout is not supported by %v client", name)
+	}
+	return nil
+}
+
+// NewConnectorFunc creates a client connector which shares the connection parameters.
+type NewConnectorFunc func(param *DefaultConnector) Connector
+
+var registry = map[string]NewConnectorFunc{}
+
+func register(name string, newFunc NewConnectorFunc) {
+	registry[name] = newFunc
+}
+
+// Clients returns client names available on this platform.
+// Clients in order of preference come first, followed by the clients which are never selected automatically.
+func Clients() []string {
+	names := []string{}
+	for _, name := range clientPreference {
+		if _, ok := registry[name]; ok {
+			names = append(names, name)
+		}
+	}
+	others := []string{}
+	for name := range registry {
+		if !slices.Contains(names, name) {
+			others = append(others, name)
+		}
+	}
+	slices.Sort(others)
+	return append(names, others...)
+}
+
+// New creates the connector of the named client.
+func New(name string, param *DefaultConnector) (Connector, error) {
+	newFunc, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("client %v is not supported (available clients: %v)", name, strings.Join(Clients(), ", "))
+	}
+	return newFunc(param), nil
+}
+
+// Detect returns the first installed client name in order of preference.
+func Detect() (string, error) {
+	for _, name := range clientPreference {
+		if installed, _ := registry[name](&DefaultConnector{}).IsInstalled(); installed {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no RDP client is installed (supported clients: %v)", strings.Join(Clients(), ", "))
+}
+
+// DefaultConnector connects with the default client of the platform.
+func (f *DefaultConnector) defaultClient() Connector {
+	return registry[clientPreference[0]](f)
+}
+
+func (f *DefaultConnector) IsInstalled() (bool, error) {
+	return f.defaultClient().IsInstalled()
+}
+
+func (f *DefaultConnector) PreConnect() error {
+	return f.defaultClient().PreConnect()
+}
+
+func (f *DefaultConnector) Connect() error {
+	return f.defaultClient().Connect()
+}
+
+func (f *DefaultConnector) PostConnect() error {
+	return f.defaultClient().PostConnect()
+}
+
+func splitUserName(userName string) (string, string) {
+	if domain, user, found := strings.Cut(userName, `\`); found {
+		return domain, user
+	}
+	return "", userName
+}
+
+func isLoopback(hostName string) bool {
+	if hostName == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(hostName)
+	return ip != nil && ip.IsLoopback()
 }
@@ -0,0 +1,205 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Password passing methods of CustomConnector
+const (
+	PasswordViaStdin = "stdin"
+	PasswordViaEnv   = "env"
+	PasswordViaFile  = "file"
+)
+
+// Environment variables passed to the custom client
+const (
+	passwordEnvName     = "EC2RDP_PASSWORD"
+	passwordFileEnvName = "EC2RDP_PASSWORD_FILE"
+	displayEnvPrefix    = "EC2RDP_"
+)
+
+// CustomConnector invokes the client command built from the template.
+// The template can contain {host}, {port}, {user} and {passwordfile} placeholders.
+type CustomConnector struct {
+	*DefaultConnector
+	Command      string
+	PasswordVia  string
+	passwordFile string
+}
+
+func init() {
+	register("custom", func(param *DefaultConnector) Connector {
+		return &CustomConnector{DefaultConnector: param, PasswordVia: PasswordViaStdin}
+	})
+}
+
+func (f *CustomConnector) IsInstalled() (bool, error) {
+	args, err := splitCommandLine(f.Command)
+	if err != nil {
+		return false, err
+	}
+	if len(args) == 0 {
+		return false, errors.New("custom client command is empty")
+	}
+	_, err = exec.LookPath(args[0])
+	if err != nil {
+		return false, fmt.Errorf("%v is not found", args[0])
+	}
+	return true, nil
+}
+
+func (f *CustomConnector) PreConnect() error {
+	switch f.PasswordVia {
+	case PasswordViaStdin, PasswordViaEnv:
+		return nil
+	case PasswordViaFile:
+		file, err := os.CreateTemp("", "ec2rdp-*.txt")
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		f.passwordFile = file.Name()
+		err = file.Chmod(0600)
+		if err == nil {
+			_, err = file.WriteString(f.PlainPassword)
+		}
+		if err != nil {
+			// do not leave the password file when PreConnect fails.
+			f.PostConnect()
+		}
+		return err
+	default:
+		return fmt.Errorf("invalid password passing method %v (stdin, env or file)", f.PasswordVia)
+	}
+}
+
+func (f *CustomConnector) Connect() error {
+	args, err := f.buildArgs()
+	if err != nil {
+		return err
+	}
+
+	// invoke custom client
+	fmt.Printf("Connect to %v:%v\n", f.HostName, f.Port)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), displayEnv(f.Display)...)
+	switch f.PasswordVia {
+	case PasswordViaStdin:
+		cmd.Stdin = strings.NewReader(f.PlainPassword + "\n")
+	case PasswordViaEnv:
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", passwordEnvName, f.PlainPassword))
+	case PasswordViaFile:
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", passwordFileEnvName, f.passwordFile))
+	}
+	if f.WaitFor {
+		cmd.Run()
+	} else {
+		err = cmd.Start()
+		if err != nil {
+			return err
+		}
+		// wait minimum time for RDP client to use credential.
+		time.Sleep(2 * time.Second)
+	}
+	return nil
+}
+
+func (f *CustomConnector) PostConnect() error {
+	if f.passwordFile == "" {
+		return nil
+	}
+	return os.Remove(f.passwordFile)
+}
+
+// displayEnv returns the display and redirection settings as EC2RDP_* environment variables.
+// Boolean values are 1 or 0, and WIDTH, HEIGHT and KEYBOARD_LAYOUT are 0 for the client default.
+func displayEnv(d DisplayOptions) []string {
+	values := []struct {
+		Name  string
+		Value string
+	}{
+		{"FULLSCREEN", boolToRDPInt(d.FullScreen)},
+		{"WIDTH", strconv.Itoa(d.Width)},
+		{"HEIGHT", strconv.Itoa(d.Height)},
+		{"MULTIMON", boolToRDPInt(d.MultiMonitor)},
+		{"CLIPBOARD", boolToRDPInt(d.Clipboard)},
+		{"DRIVES", boolToRDPInt(d.Drives)},
+		{"PRINTERS", boolToRDPInt(d.Printers)},
+		{"AUDIO", d.Audio},
+		{"KEYBOARD_LAYOUT", fmt.Sprintf("0x%08X", d.KeyboardLayout)},
+	}
+	env := []string{}
+	for _, v := range values {
+		env = append(env, fmt.Sprintf("%v%v=%v", displayEnvPrefix, v.Name, v.Value))
+	}
+	return env
+}
+
+func (f *CustomConnector) buildArgs() ([]string, error) {
+	if strings.Contains(f.Command, "{password}") {
+		return nil, errors.New("{password} placeholder is not allowed. Use stdin, env or file to pass the password")
+	}
+	args, err := splitCommandLine(f.Command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("custom client command is empty")
+	}
+	replacer := strings.NewReplacer(
+		"{host}", f.HostName,
+		"{port}", strconv.Itoa(f.Port),
+		"{user}", f.UserName,
+		"{passwordfile}", f.passwordFile,
+	)
+	for i := range args {
+		args[i] = replacer.Replace(args[i])
+	}
+	return args, nil
+}
+
+// splitCommandLine splits the command line by spaces except for quoted strings.
+// Backslash is not treated as an escape character to keep Windows paths as they are.
+func splitCommandLine(commandLine string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range commandLine {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("custom client command has unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
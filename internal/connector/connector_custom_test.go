@@ -0,0 +1,111 @@
+package connector
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func Test_splitCommandLine(t *testing.T) {
+	cases := []struct {
+		CommandLine string
+		Args        []string
+		Valid       bool
+	}{
+		{"myclient --host {host} --port {port}", []string{"myclient", "--host", "{host}", "--port", "{port}"}, true},
+		{`"C:\Program Files\My Client\client.exe"  /v:{host}`, []string{`C:\Program Files\My Client\client.exe`, "/v:{host}"}, true},
+		{`myclient --title 'ec2rdp session'`, []string{"myclient", "--title", "ec2rdp session"}, true},
+		{`myclient --empty ""`, []string{"myclient", "--empty", ""}, true},
+		{`myclient "unterminated`, nil, false},
+	}
+	for _, c := range cases {
+		args, err := splitCommandLine(c.CommandLine)
+		if c.Valid {
+			if err != nil || !slices.Equal(args, c.Args) {
+				t.Errorf("Invalid split result of %v : %q", c.CommandLine, args)
+			}
+		} else {
+			if err == nil {
+				t.Errorf("%v must be invalid", c.CommandLine)
+			}
+		}
+	}
+}
+
+func Test_CustomConnector_buildArgs(t *testing.T) {
+	con := &CustomConnector{
+		DefaultConnector: &DefaultConnector{HostName: "localhost", Port: 33389, UserName: "Administrator", PlainPassword: "P@ssw0rd"},
+		Command:          "myclient --host {host} --port {port} --user {user}",
+	}
+	args, err := con.buildArgs()
+	if err != nil {
+		t.Error("Failed to build arguments")
+	}
+	expected := []string{"myclient", "--host", "localhost", "--port", "33389", "--user", "Administrator"}
+	if !slices.Equal(args, expected) {
+		t.Errorf("Invalid arguments %v", args)
+	}
+
+	// Fail when password placeholder is specified
+	con.Command = "myclient --password {password}"
+	_, err = con.buildArgs()
+	if err == nil {
+		t.Error("Password must not be passed by arguments")
+	}
+}
+
+func Test_CustomConnector_Connect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is required")
+	}
+	cases := []struct {
+		PasswordVia string
+		Script      string
+	}{
+		{PasswordViaStdin, `cat > "$0"`},
+		{PasswordViaEnv, `printf %s "$EC2RDP_PASSWORD" > "$0"`},
+		{PasswordViaFile, `cat "$EC2RDP_PASSWORD_FILE" > "$0"`},
+	}
+	for _, c := range cases {
+		// pass the output file path by {host} placeholder
+		output := filepath.Join(t.TempDir(), "output.txt")
+		con := &CustomConnector{
+			DefaultConnector: &DefaultConnector{HostName: output, Port: 3389, UserName: "Administrator", PlainPassword: "P@ssw0rd", WaitFor: true},
+			Command:          "sh -c '" + c.Script + "' {host}",
+			PasswordVia:      c.PasswordVia,
+		}
+		if _, err := con.IsInstalled(); err != nil {
+			t.Fatal("sh is not found")
+		}
+		if err := con.PreConnect(); err != nil {
+			t.Errorf("PreConnect failed (%v)", c.PasswordVia)
+		}
+		if err := con.Connect(); err != nil {
+			t.Errorf("Connect failed (%v)", c.PasswordVia)
+		}
+		passwordFile := con.passwordFile
+		if err := con.PostConnect(); err != nil {
+			t.Errorf("PostConnect failed (%v)", c.PasswordVia)
+		}
+		result, _ := os.ReadFile(output)
+		if string(result) != "P@ssw0rd" && string(result) != "P@ssw0rd\n" {
+			t.Errorf("Password is not passed via %v : %q", c.PasswordVia, result)
+		}
+		if passwordFile != "" {
+			if _, err := os.Stat(passwordFile); err == nil {
+				t.Error("Password file must be removed")
+			}
+		}
+	}
+}
+
+func Test_displayEnv(t *testing.T) {
+	env := displayEnv(DisplayOptions{Width: 1280, Height: 800, Clipboard: true, Audio: AudioOff, KeyboardLayout: 0x00000411})
+	for _, v := range []string{"EC2RDP_FULLSCREEN=0", "EC2RDP_WIDTH=1280", "EC2RDP_HEIGHT=800", "EC2RDP_MULTIMON=0", "EC2RDP_CLIPBOARD=1", "EC2RDP_DRIVES=0", "EC2RDP_PRINTERS=0", "EC2RDP_AUDIO=off", "EC2RDP_KEYBOARD_LAYOUT=0x00000411"} {
+		if !slices.Contains(env, v) {
+			t.Errorf("Environment variables must contain %v (%v)", v, env)
+		}
+	}
+}
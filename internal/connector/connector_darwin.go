@@ -3,12 +3,35 @@
 package connector
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 )
 
-func (f *DefaultConnector) IsInstalled() (bool, error) {
+var clientPreference = []string{"parallels", "windows-app", "freerdp"}
+
+type ParallelsConnector struct {
+	*DefaultConnector
+}
+
+type WindowsAppConnector struct {
+	*DefaultConnector
+}
+
+func init() {
+	register("parallels", func(param *DefaultConnector) Connector {
+		return &ParallelsConnector{DefaultConnector: param}
+	})
+	register("windows-app", func(param *DefaultConnector) Connector {
+		return &WindowsAppConnector{DefaultConnector: param}
+	})
+}
+
+func (f *ParallelsConnector) IsInstalled() (bool, error) {
 	_, err := os.Stat("/Applications/Parallels Client.app")
 	if err != nil {
 		return false, fmt.Errorf("%v is not installed", "Parallels Client")
@@ -16,16 +39,16 @@ func (f *DefaultConnector) IsInstalled() (bool, error) {
 	return true, nil
 }
 
-func (f *DefaultConnector) PreConnect() error {
+func (f *ParallelsConnector) PreConnect() error {
 	// do nothing
 	return nil
 }
 
-func (f *DefaultConnector) Connect() error {
+func (f *ParallelsConnector) Connect() error {
 	// start Parallels Client
 	fmt.Printf("Connect to %v:%v\n", f.HostName, f.Port)
 	var rasUrl = fmt.Sprintf("tuxclient:///?Command=LaunchApp&ConnType=2&Server=%v&Backup=&Port=%v&LoginEx=%v&Password=%v", f.HostName, f.Port, f.UserName, f.PlainPassword)
-	cmd := exec.Command("open", rasUrl)
+	cmd := exec.Command("open", rasUrl+parallelsDisplayParams(f.Display))
 	cmd.Start()
 	if f.WaitFor {
 		// To prevent password appearing from arguments, wait for the .app process.
@@ -35,7 +58,110 @@ func (f *DefaultConnector) Connect() error {
 	return nil
 }
 
-func (f *DefaultConnector) PostConnect() error {
+func (f *ParallelsConnector) PostConnect() error {
 	// do nothing
 	return nil
 }
+
+func parallelsDisplayParams(d DisplayOptions) string {
+	params := []string{}
+	if d.FullScreen {
+		params = append(params, "FullScreen=1")
+	} else {
+		params = append(params, "FullScreen=0")
+		if d.Width > 0 && d.Height > 0 {
+			params = append(params, fmt.Sprintf("Width=%v", d.Width), fmt.Sprintf("Height=%v", d.Height))
+		}
+	}
+	params = append(params,
+		fmt.Sprintf("MultiMonitor=%v", boolToRDPInt(d.MultiMonitor)),
+		fmt.Sprintf("RedirectClipboard=%v", boolToRDPInt(d.Clipboard)),
+		fmt.Sprintf("RedirectDrives=%v", boolToRDPInt(d.Drives)),
+		fmt.Sprintf("RedirectPrinters=%v", boolToRDPInt(d.Printers)),
+	)
+	switch d.Audio {
+	case AudioRemote:
+		params = append(params, "AudioMode=1")
+	case AudioOff:
+		params = append(params, "AudioMode=2")
+	default:
+		params = append(params, "AudioMode=0")
+	}
+	if d.KeyboardLayout != 0 {
+		params = append(params, fmt.Sprintf("KeyboardLayout=%v", d.KeyboardLayout))
+	}
+	return "&" + strings.Join(params, "&")
+}
+
+// Windows App was formerly named Microsoft Remote Desktop.
+var windowsAppPaths = []string{"/Applications/Windows App.app", "/Applications/Microsoft Remote Desktop.app"}
+
+func findWindowsApp() (string, error) {
+	for _, path := range windowsAppPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%v is not installed", "Windows App")
+}
+
+func (f *WindowsAppConnector) IsInstalled() (bool, error) {
+	_, err := findWindowsApp()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *WindowsAppConnector) PreConnect() error {
+	// Windows App can't receive password, so copy it to the clipboard.
+	cmd := exec.Command("pbcopy")
+	cmd.Stdin = strings.NewReader(f.PlainPassword)
+	err := cmd.Run()
+	if err != nil {
+		return errors.New("failed to copy password to the clipboard")
+	}
+	fmt.Println("Password is copied to the clipboard")
+	return nil
+}
+
+func (f *WindowsAppConnector) Connect() error {
+	appPath, err := findWindowsApp()
+	if err != nil {
+		return err
+	}
+	// start Windows App
+	fmt.Printf("Connect to %v:%v\n", f.HostName, f.Port)
+	cmd := exec.Command("open", "-a", appPath, windowsAppURL(f.DefaultConnector))
+	cmd.Start()
+	if f.WaitFor {
+		cmd := exec.Command("open", "--wait-apps", appPath)
+		cmd.Run()
+	} else {
+		// wait minimum time for RDP client to start.
+		time.Sleep(2 * time.Second)
+	}
+	return nil
+}
+
+func (f *WindowsAppConnector) PostConnect() error {
+	if !f.WaitFor {
+		// keep the password in the clipboard for the user to paste.
+		return nil
+	}
+	cmd := exec.Command("pbcopy")
+	cmd.Stdin = strings.NewReader("")
+	return cmd.Run()
+}
+
+func windowsAppURL(f *DefaultConnector) string {
+	// ref : https://learn.microsoft.com/en-us/windows-server/remote/remote-desktop-services/clients/remote-desktop-uri
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+	query := []string{}
+	for _, p := range rdpProperties(f) {
+		query = append(query, fmt.Sprintf("%v=%v:%v", escape(p.Name), p.Type, escape(p.Value)))
+	}
+	return "rdp://" + strings.Join(query, "&")
+}
@@ -0,0 +1,182 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+type FreeRDPConnector struct {
+	*DefaultConnector
+}
+
+func init() {
+	register("freerdp", func(param *DefaultConnector) Connector {
+		return &FreeRDPConnector{DefaultConnector: param}
+	})
+}
+
+func (f *FreeRDPConnector) IsInstalled() (bool, error) {
+	_, _, err := findFreeRDP()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *FreeRDPConnector) PreConnect() error {
+	// do nothing
+	return nil
+}
+
+func (f *FreeRDPConnector) Connect() error {
+	path, major, err := findFreeRDP()
+	if err != nil {
+		return err
+	}
+
+	// invoke FreeRDP
+	fmt.Printf("Connect to %v:%v\n", f.HostName, f.Port)
+	cmd := exec.Command(path, freeRDPArgs(f.DefaultConnector, major)...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	// To prevent password appearing from arguments, pass the password via stdin (/from-stdin:force).
+	io.WriteString(stdin, freeRDPStdin(f.DefaultConnector))
+	stdin.Close()
+	if f.WaitFor {
+		cmd.Wait()
+	} else {
+		// wait minimum time for RDP client to read credential.
+		time.Sleep(2 * time.Second)
+	}
+	return nil
+}
+
+func (f *FreeRDPConnector) PostConnect() error {
+	// do nothing
+	return nil
+}
+
+// freeRDPCandidates returns FreeRDP client names in order of preference.
+// FreeRDP 3 packages on some distributions install the clients with "3" suffix.
+func freeRDPCandidates() []string {
+	switch {
+	case runtime.GOOS == "windows":
+		return []string{"wfreerdp3", "sdl-freerdp3", "wfreerdp", "sdl-freerdp"}
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return []string{"wlfreerdp3", "sdl-freerdp3", "wlfreerdp", "sdl-freerdp", "xfreerdp3", "xfreerdp"}
+	default:
+		return []string{"xfreerdp3", "sdl-freerdp3", "xfreerdp", "sdl-freerdp"}
+	}
+}
+
+func findFreeRDP() (string, int, error) {
+	for _, name := range freeRDPCandidates() {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		output, _ := exec.Command(path, "--version").Output()
+		major, err := parseFreeRDPMajorVersion(string(output))
+		if err != nil {
+			continue
+		}
+		return path, major, nil
+	}
+	return "", 0, errors.New("FreeRDP (xfreerdp, wlfreerdp or sdl-freerdp) is not found")
+}
+
+var freeRDPVersionPattern = regexp.MustCompile(`version\s+(\d+\.\d+\.\d+)`)
+
+func parseFreeRDPMajorVersion(output string) (int, error) {
+	// e.g. "This is FreeRDP version 3.5.1 (3.5.1)"
+	m := freeRDPVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return 0, errors.New("failed to get FreeRDP version")
+	}
+	v, err := version.NewVersion(m[1])
+	if err != nil {
+		return 0, errors.New("failed to get FreeRDP version")
+	}
+	major := v.Segments()[0]
+	if major < 2 {
+		return 0, fmt.Errorf("FreeRDP 2.0.0 later is required (current version=%s)", v)
+	}
+	return major, nil
+}
+
+// freeRDPStdin returns the input of /from-stdin:force.
+// FreeRDP reads only the password before connecting, because the user and domain are passed with /u and /d.
+func freeRDPStdin(f *DefaultConnector) string {
+	return f.PlainPassword + "\n"
+}
+
+func freeRDPArgs(f *DefaultConnector, major int) []string {
+	args := []string{fmt.Sprintf("/v:%v:%v", f.HostName, f.Port)}
+	domain, user := splitUserName(f.UserName)
+	args = append(args, fmt.Sprintf("/u:%v", user))
+	if domain != "" {
+		args = append(args, fmt.Sprintf("/d:%v", domain))
+	}
+	args = append(args, "/from-stdin:force")
+	// display and redirection
+	d := f.Display
+	if d.FullScreen {
+		args = append(args, "/f")
+	} else if d.Width > 0 && d.Height > 0 {
+		args = append(args, fmt.Sprintf("/size:%vx%v", d.Width, d.Height))
+	}
+	if d.MultiMonitor {
+		args = append(args, "/multimon")
+	}
+	if d.Clipboard {
+		args = append(args, "+clipboard")
+	} else {
+		args = append(args, "-clipboard")
+	}
+	if d.Drives {
+		args = append(args, "/drives")
+	}
+	if d.Printers {
+		args = append(args, "/printer")
+	}
+	switch d.Audio {
+	case AudioRemote:
+		args = append(args, "/audio-mode:1")
+	case AudioOff:
+		args = append(args, "/audio-mode:2")
+	default:
+		args = append(args, "/sound")
+	}
+	if d.KeyboardLayout != 0 {
+		if major >= 3 {
+			args = append(args, fmt.Sprintf("/kbd:layout:0x%08X", d.KeyboardLayout))
+		} else {
+			args = append(args, fmt.Sprintf("/kbd:0x%08X", d.KeyboardLayout))
+		}
+	}
+	// Tunnel endpoints share localhost, so certificates of different instances can't be pinned.
+	certMode := "tofu"
+	if isLoopback(f.HostName) {
+		certMode = "ignore"
+	}
+	if major >= 3 {
+		args = append(args, fmt.Sprintf("/cert:%v", certMode))
+	} else {
+		args = append(args, fmt.Sprintf("/cert-%v", certMode))
+	}
+	return args
+}
@@ -0,0 +1,77 @@
+package connector
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_parseFreeRDPMajorVersion(t *testing.T) {
+	cases := []struct {
+		Output string
+		Major  int
+		Valid  bool
+	}{
+		{"This is FreeRDP version 2.11.2 (2.11.2)", 2, true},
+		{"This is FreeRDP version 3.5.1 (3.5.1)", 3, true},
+		{"This is FreeRDP version 1.2.0 (git n/a)", 0, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		major, err := parseFreeRDPMajorVersion(c.Output)
+		if c.Valid {
+			if err != nil || major != c.Major {
+				t.Errorf("%q must be version %v", c.Output, c.Major)
+			}
+		} else {
+			if err == nil {
+				t.Errorf("%q must be invalid", c.Output)
+			}
+		}
+	}
+}
+
+func Test_freeRDPArgs(t *testing.T) {
+	// FreeRDP 3 with public host
+	con := &DefaultConnector{
+		HostName: "public.example.com", Port: 3389, UserName: "Administrator",
+		Display: DisplayOptions{FullScreen: true, Clipboard: true, Audio: AudioLocal},
+	}
+	args := freeRDPArgs(con, 3)
+	expected := []string{"/v:public.example.com:3389", "/u:Administrator", "/from-stdin:force", "/f", "+clipboard", "/sound", "/cert:tofu"}
+	if !slices.Equal(args, expected) {
+		t.Errorf("Invalid arguments %v", args)
+	}
+
+	// FreeRDP 2 with tunnel endpoint and domain user
+	con = &DefaultConnector{
+		HostName: "localhost", Port: 33389, UserName: `EXAMPLE\Admin`,
+		Display: DisplayOptions{Width: 1280, Height: 800, MultiMonitor: true, Drives: true, Printers: true, Audio: AudioOff, KeyboardLayout: 0x411},
+	}
+	args = freeRDPArgs(con, 2)
+	expected = []string{"/v:localhost:33389", "/u:Admin", "/d:EXAMPLE", "/from-stdin:force", "/size:1280x800", "/multimon", "-clipboard", "/drives", "/printer", "/audio-mode:2", "/kbd:0x00000411", "/cert-ignore"}
+	if !slices.Equal(args, expected) {
+		t.Errorf("Invalid arguments %v", args)
+	}
+
+	// password must not appear in arguments
+	con = &DefaultConnector{HostName: "127.0.0.1", Port: 33389, UserName: "Administrator", PlainPassword: "P@ssw0rd"}
+	for _, arg := range freeRDPArgs(con, 3) {
+		if strings.HasPrefix(arg, "/p:") || strings.Contains(arg, "P@ssw0rd") {
+			t.Error("Password must not be passed by arguments")
+		}
+	}
+}
+
+func Test_freeRDPStdin(t *testing.T) {
+	// the password is the only input with or without domain
+	for _, userName := range []string{"Administrator", `EXAMPLE\Administrator`} {
+		con := &DefaultConnector{UserName: userName, PlainPassword: "P@ssw0rd"}
+		if input := freeRDPStdin(con); input != "P@ssw0rd\n" {
+			t.Errorf("Invalid stdin %q for user %v", input, userName)
+		}
+		if !slices.Contains(freeRDPArgs(con, 3), "/from-stdin:force") {
+			t.Errorf("/from-stdin:force is required for user %v", userName)
+		}
+	}
+}
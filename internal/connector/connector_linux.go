@@ -0,0 +1,140 @@
+//go:build linux
+
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var clientPreference = []string{"freerdp", "remmina"}
+
+type RemminaConnector struct {
+	*DefaultConnector
+	profilePath string
+}
+
+func init() {
+	register("remmina", func(param *DefaultConnector) Connector {
+		return &RemminaConnector{DefaultConnector: param}
+	})
+}
+
+func (f *RemminaConnector) IsInstalled() (bool, error) {
+	_, err := exec.LookPath("remmina")
+	if err != nil {
+		return false, fmt.Errorf("%v is not found", "remmina")
+	}
+	return true, nil
+}
+
+func (f *RemminaConnector) PreConnect() error {
+	// To prevent password appearing from arguments, save the password encrypted by Remmina to a connection profile.
+	password, err := encryptRemminaPassword(f.PlainPassword)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp("", "ec2rdp-*.remmina")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f.profilePath = file.Name()
+	_, err = file.WriteString(remminaProfile(f.DefaultConnector, password))
+	if err != nil {
+		// do not leave the profile when PreConnect fails.
+		f.PostConnect()
+	}
+	return err
+}
+
+func (f *RemminaConnector) Connect() error {
+	// invoke Remmina
+	fmt.Printf("Connect to %v:%v\n", f.HostName, f.Port)
+	cmd := exec.Command("remmina", "--connect", f.profilePath)
+	if f.WaitFor {
+		// Remmina returns immediately when another Remmina process is already running.
+		cmd.Run()
+	} else {
+		cmd.Start()
+		// wait minimum time for RDP client to read the profile.
+		time.Sleep(2 * time.Second)
+	}
+	return nil
+}
+
+func (f *RemminaConnector) PostConnect() error {
+	if f.profilePath == "" {
+		return nil
+	}
+	return os.Remove(f.profilePath)
+}
+
+func encryptRemminaPassword(password string) (string, error) {
+	cmd := exec.Command("remmina", "--encrypt-password")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	cmd.Stdin = strings.NewReader(password + "\n")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt password with remmina: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		_, encrypted, found := strings.Cut(scanner.Text(), "Encrypted password: ")
+		if found {
+			return strings.TrimSpace(encrypted), nil
+		}
+	}
+	return "", errors.New("failed to encrypt password with remmina")
+}
+
+func remminaProfile(f *DefaultConnector, encryptedPassword string) string {
+	domain, user := splitUserName(f.UserName)
+	lines := []string{
+		"[remmina]",
+		fmt.Sprintf("name=ec2rdp %v:%v", f.HostName, f.Port),
+		"protocol=RDP",
+		fmt.Sprintf("server=%v:%v", f.HostName, f.Port),
+		fmt.Sprintf("username=%v", user),
+		fmt.Sprintf("domain=%v", domain),
+		fmt.Sprintf("password=%v", encryptedPassword),
+	}
+	// display and redirection
+	d := f.Display
+	if d.FullScreen {
+		lines = append(lines, "viewmode=4")
+	} else {
+		lines = append(lines, "viewmode=1")
+		if d.Width > 0 && d.Height > 0 {
+			lines = append(lines, "resolution_mode=2", fmt.Sprintf("resolution_width=%v", d.Width), fmt.Sprintf("resolution_height=%v", d.Height))
+		}
+	}
+	lines = append(lines,
+		fmt.Sprintf("multimon=%v", boolToRDPInt(d.MultiMonitor)),
+		fmt.Sprintf("disableclipboard=%v", boolToRDPInt(!d.Clipboard)),
+		fmt.Sprintf("shareprinter=%v", boolToRDPInt(d.Printers)),
+	)
+	if d.Drives {
+		// Remmina shares a folder instead of drives.
+		home, _ := os.UserHomeDir()
+		lines = append(lines, fmt.Sprintf("sharefolder=%v", home))
+	}
+	switch d.Audio {
+	case AudioRemote:
+		lines = append(lines, "sound=remote")
+	case AudioOff:
+		lines = append(lines, "sound=off")
+	default:
+		lines = append(lines, "sound=local")
+	}
+	if isLoopback(f.HostName) {
+		lines = append(lines, "cert_ignore=1")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,5 @@
+//go:build !windows && !darwin && !linux
+
+package connector
+
+var clientPreference = []string{"freerdp"}
@@ -0,0 +1,132 @@
+package connector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type RDPFileConnector struct {
+	*DefaultConnector
+	FilePath     string
+	ShowPassword bool
+}
+
+func init() {
+	register("rdpfile", func(param *DefaultConnector) Connector {
+		return &RDPFileConnector{DefaultConnector: param}
+	})
+}
+
+func (f *RDPFileConnector) IsInstalled() (bool, error) {
+	// no application is required
+	return true, nil
+}
+
+func (f *RDPFileConnector) PreConnect() error {
+	if f.FilePath == "" {
+		f.FilePath = fmt.Sprintf("%v_%v.rdp", f.HostName, f.Port)
+	}
+	return nil
+}
+
+func (f *RDPFileConnector) Connect() error {
+	err := os.WriteFile(f.FilePath, []byte(buildRDPFile(f.DefaultConnector)), 0644)
+	if err != nil {
+		return err
+	}
+	path, _ := filepath.Abs(f.FilePath)
+	fmt.Printf("Save RDP file to %v\n", path)
+	if f.ShowPassword {
+		fmt.Printf("Password: %v\n", f.PlainPassword)
+	}
+	if f.WaitFor {
+		// keep the tunnel open while the file is in use.
+		fmt.Print("Press Enter to close the connection...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	return nil
+}
+
+func (f *RDPFileConnector) PostConnect() error {
+	// keep the file
+	return nil
+}
+
+type rdpProperty struct {
+	Name  string
+	Type  string // "s" for string, "i" for integer
+	Value string
+}
+
+// rdpProperties returns Remote Desktop connection settings.
+// ref : https://learn.microsoft.com/en-us/azure/virtual-desktop/rdp-properties
+func rdpProperties(f *DefaultConnector) []rdpProperty {
+	domain, user := splitUserName(f.UserName)
+	props := []rdpProperty{
+		{"full address", "s", fmt.Sprintf("%v:%v", f.HostName, f.Port)},
+		{"server port", "i", strconv.Itoa(f.Port)},
+		{"username", "s", user},
+	}
+	if domain != "" {
+		props = append(props, rdpProperty{"domain", "s", domain})
+	}
+	d := f.Display
+	if d.FullScreen {
+		props = append(props, rdpProperty{"screen mode id", "i", "2"})
+	} else {
+		props = append(props, rdpProperty{"screen mode id", "i", "1"})
+		if d.Width > 0 && d.Height > 0 {
+			props = append(props,
+				rdpProperty{"desktopwidth", "i", strconv.Itoa(d.Width)},
+				rdpProperty{"desktopheight", "i", strconv.Itoa(d.Height)},
+			)
+		}
+	}
+	props = append(props,
+		rdpProperty{"use multimon", "i", boolToRDPInt(d.MultiMonitor)},
+		rdpProperty{"redirectclipboard", "i", boolToRDPInt(d.Clipboard)},
+		rdpProperty{"redirectprinters", "i", boolToRDPInt(d.Printers)},
+		rdpProperty{"redirectsmartcards", "i", "0"},
+	)
+	if d.Drives {
+		props = append(props, rdpProperty{"drivestoredirect", "s", "*"})
+	} else {
+		props = append(props, rdpProperty{"drivestoredirect", "s", ""})
+	}
+	switch d.Audio {
+	case AudioRemote:
+		props = append(props, rdpProperty{"audiomode", "i", "1"})
+	case AudioOff:
+		props = append(props, rdpProperty{"audiomode", "i", "2"})
+	default:
+		props = append(props, rdpProperty{"audiomode", "i", "0"})
+	}
+	props = append(props, rdpProperty{"prompt for credentials", "i", "0"})
+	if isLoopback(f.HostName) {
+		// tunnel endpoint can't match the server certificate name.
+		props = append(props, rdpProperty{"authentication level", "i", "0"})
+	} else {
+		props = append(props, rdpProperty{"authentication level", "i", "2"})
+	}
+	return props
+}
+
+func boolToRDPInt(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// buildRDPFile returns the content of Remote Desktop connection file.
+func buildRDPFile(f *DefaultConnector) string {
+	var b strings.Builder
+	for _, p := range rdpProperties(f) {
+		fmt.Fprintf(&b, "%v:%v:%v\r\n", p.Name, p.Type, p.Value)
+	}
+	return b.String()
+}
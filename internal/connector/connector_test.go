@@ -0,0 +1,110 @@
+package connector
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_Clients(t *testing.T) {
+	// all clients in order of preference must be registered
+	clients := Clients()
+	if !slices.Equal(clients[:len(clientPreference)], clientPreference) {
+		t.Errorf("Invalid clients %v", clients)
+	}
+	for _, name := range []string{"freerdp", "rdpfile"} {
+		if !slices.Contains(clients, name) {
+			t.Errorf("%v is available on all platforms", name)
+		}
+	}
+}
+
+func Test_New(t *testing.T) {
+	// Fail when unsupported client is specified
+	_, err := New("non-existent-client", &DefaultConnector{})
+	if err == nil {
+		t.Error("Must fail when unsupported client is specified")
+	}
+}
+
+func Test_splitUserName(t *testing.T) {
+	cases := []struct {
+		UserName string
+		Domain   string
+		User     string
+	}{
+		{"Administrator", "", "Administrator"},
+		{`EXAMPLE\Admin`, "EXAMPLE", "Admin"},
+	}
+	for _, c := range cases {
+		domain, user := splitUserName(c.UserName)
+		if domain != c.Domain || user != c.User {
+			t.Errorf("Invalid split result of %v", c.UserName)
+		}
+	}
+}
+
+func Test_buildRDPFile(t *testing.T) {
+	// public host
+	content := buildRDPFile(&DefaultConnector{HostName: "public.example.com", Port: 3389, UserName: "Administrator", PlainPassword: "P@ssw0rd"})
+	for _, line := range []string{"full address:s:public.example.com:3389", "username:s:Administrator", "authentication level:i:2"} {
+		if !strings.Contains(content, line+"\r\n") {
+			t.Errorf("RDP file must contain %q", line)
+		}
+	}
+	if strings.Contains(content, "P@ssw0rd") {
+		t.Error("RDP file must not contain password")
+	}
+
+	// tunnel endpoint with domain user
+	content = buildRDPFile(&DefaultConnector{HostName: "localhost", Port: 33389, UserName: `EXAMPLE\Admin`})
+	for _, line := range []string{"full address:s:localhost:33389", "server port:i:33389", "username:s:Admin", "domain:s:EXAMPLE", "authentication level:i:0"} {
+		if !strings.Contains(content, line+"\r\n") {
+			t.Errorf("RDP file must contain %q", line)
+		}
+	}
+}
+
+func Test_buildRDPFile_Display(t *testing.T) {
+	// window mode with redirections
+	content := buildRDPFile(&DefaultConnector{
+		HostName: "localhost", Port: 33389, UserName: "Administrator",
+		Display: DisplayOptions{Width: 1280, Height: 800, MultiMonitor: true, Drives: true, Audio: AudioOff},
+	})
+	for _, line := range []string{"screen mode id:i:1", "desktopwidth:i:1280", "desktopheight:i:800", "use multimon:i:1", "redirectclipboard:i:0", "drivestoredirect:s:*", "audiomode:i:2"} {
+		if !strings.Contains(content, line+"\r\n") {
+			t.Errorf("RDP file must contain %q", line)
+		}
+	}
+
+	// full screen mode ignores window size
+	content = buildRDPFile(&DefaultConnector{
+		HostName: "localhost", Port: 33389, UserName: "Administrator",
+		Display: DisplayOptions{FullScreen: true, Width: 1280, Height: 800, Clipboard: true, Audio: AudioLocal},
+	})
+	for _, line := range []string{"screen mode id:i:2", "redirectclipboard:i:1", "audiomode:i:0"} {
+		if !strings.Contains(content, line+"\r\n") {
+			t.Errorf("RDP file must contain %q", line)
+		}
+	}
+	if strings.Contains(content, "desktopwidth") {
+		t.Error("RDP file must not contain window size in full screen mode")
+	}
+}
+
+func Test_ValidateDisplay(t *testing.T) {
+	d := DisplayOptions{KeyboardLayout: 0x00000411}
+	for _, name := range []string{"freerdp", "parallels", "custom"} {
+		if err := ValidateDisplay(name, d); err != nil {
+			t.Errorf("%v must support keyboard layout (%v)", name, err)
+		}
+	}
+	for _, name := range []string{"mstsc", "rdpfile", "windows-app", "remmina"} {
+		if err := ValidateDisplay(name, d); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%v must not support keyboard layout (%v)", name, err)
+		}
+		if err := ValidateDisplay(name, DisplayOptions{}); err != nil {
+			t.Errorf("%v must accept the client default keyboard layout (%v)", name, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package connector
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"time"
 
@@ -12,7 +13,20 @@ import (
 	"golang.org/x/text/transform"
 )
 
-func (f *DefaultConnector) IsInstalled() (bool, error) {
+var clientPreference = []string{"mstsc", "freerdp"}
+
+type MstscConnector struct {
+	*DefaultConnector
+	rdpFilePath string
+}
+
+func init() {
+	register("mstsc", func(param *DefaultConnector) Connector {
+		return &MstscConnector{DefaultConnector: param}
+	})
+}
+
+func (f *MstscConnector) IsInstalled() (bool, error) {
 	_, err := exec.LookPath("mstsc")
 	if err != nil {
 		return false, fmt.Errorf("%v is not found", "mstsc.exe")
@@ -20,7 +34,7 @@ func (f *DefaultConnector) IsInstalled() (bool, error) {
 	return true, nil
 }
 
-func (f *DefaultConnector) PreConnect() error {
+func (f *MstscConnector) PreConnect() error {
 	fmt.Printf("Save credential TERMSRV/%v to Credential Manager\n", f.HostName)
 	//cmd := exec.Command("cmdkey", fmt.Sprintf("/generic:TERMSRV/%v", f.HostName), fmt.Sprintf("/user:%v", f.UserName), fmt.Sprintf("/pass:%v", f.PlainPassword))
 	//cmd.Run()
@@ -36,13 +50,36 @@ func (f *DefaultConnector) PreConnect() error {
 	cred.Persist = wincred.PersistEnterprise
 	cred.UserName = f.UserName
 	cred.CredentialBlob = blob
-	return cred.Write()
+	err = cred.Write()
+	if err != nil {
+		return err
+	}
+
+	// mstsc has no switches for redirection settings, so pass them by .rdp file.
+	err = f.writeRDPFile()
+	if err != nil {
+		// do not leave the credential when PreConnect fails.
+		f.PostConnect()
+		return err
+	}
+	return nil
+}
+
+func (f *MstscConnector) writeRDPFile() error {
+	file, err := os.CreateTemp("", "ec2rdp-*.rdp")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f.rdpFilePath = file.Name()
+	_, err = file.WriteString(buildRDPFile(f.DefaultConnector))
+	return err
 }
 
-func (f *DefaultConnector) Connect() error {
+func (f *MstscConnector) Connect() error {
 	// invoke mstsc
 	fmt.Printf("Connect to %v:%v\n", f.HostName, f.Port)
-	cmd := exec.Command("mstsc", fmt.Sprintf("/v:%v:%v", f.HostName, f.Port), "/f")
+	cmd := exec.Command("mstsc", mstscArgs(f.DefaultConnector, f.rdpFilePath)...)
 	if f.WaitFor {
 		cmd.Run()
 	} else {
@@ -53,7 +90,10 @@ func (f *DefaultConnector) Connect() error {
 	return nil
 }
 
-func (f *DefaultConnector) PostConnect() error {
+func (f *MstscConnector) PostConnect() error {
+	if f.rdpFilePath != "" {
+		os.Remove(f.rdpFilePath)
+	}
 	fmt.Printf("Delete credential TERMSRV/%v from Credential Manager\n", f.HostName)
 	//cmd := exec.Command("cmdkey", fmt.Sprintf("/delete:TERMSRV/%v", f.HostName))
 	//cmd.Run()
@@ -65,3 +105,20 @@ func (f *DefaultConnector) PostConnect() error {
 	}
 	return cred.Delete()
 }
+
+func mstscArgs(f *DefaultConnector, rdpFilePath string) []string {
+	args := []string{}
+	if rdpFilePath != "" {
+		args = append(args, rdpFilePath)
+	}
+	args = append(args, fmt.Sprintf("/v:%v:%v", f.HostName, f.Port))
+	if f.Display.FullScreen {
+		args = append(args, "/f")
+	} else if f.Display.Width > 0 && f.Display.Height > 0 {
+		args = append(args, fmt.Sprintf("/w:%v", f.Display.Width), fmt.Sprintf("/h:%v", f.Display.Height))
+	}
+	if f.Display.MultiMonitor {
+		args = append(args, "/multimon")
+	}
+	return args
+}
@@ -0,0 +1,190 @@
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+var ErrCanceled = errors.New("canceled")
+
+// key codes
+const (
+	keyCtrlC     = 0x03
+	keyCtrlN     = 0x0e
+	keyCtrlP     = 0x10
+	keyCtrlU     = 0x15
+	keyBackspace = 0x08
+	keyDelete    = 0x7f
+	keyEscape    = 0x1b
+)
+
+// Picker is the fuzzy-searchable selector on the terminal.
+// Type to filter items, Up/Down (Ctrl-P/Ctrl-N) to move, Enter to select and Ctrl-C to cancel.
+type Picker struct {
+	Prompt string
+	Header string
+	Items  []string
+	Height int // the max number of items shown at once
+
+	query   []rune
+	matched []int
+	cursor  int
+	offset  int
+	lines   int
+}
+
+// Match reports whether all space separated terms of the query are contained in the text as subsequences, ignoring case.
+func Match(query string, text string) bool {
+	text = strings.ToLower(text)
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		rest := text
+		for _, r := range term {
+			i := strings.IndexRune(rest, r)
+			if i < 0 {
+				return false
+			}
+			rest = rest[i+len(string(r)):]
+		}
+	}
+	return true
+}
+
+// RunTerminal runs the picker on the terminal, and returns the index of selected item.
+func (p *Picker) RunTerminal(in *os.File, out *os.File) (int, error) {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return -1, err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	restore := enableVirtualTerminal(out)
+	defer restore()
+	return p.Run(in, out)
+}
+
+// Run reads keys from in and draws the picker to out, and returns the index of selected item.
+// The input must be in raw mode.
+func (p *Picker) Run(in io.Reader, out io.Writer) (int, error) {
+	if len(p.Items) == 0 {
+		return -1, errors.New("no item to choose")
+	}
+	if p.Height <= 0 {
+		p.Height = 10
+	}
+	p.filter()
+	reader := bufio.NewReader(in)
+	for {
+		p.draw(out)
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			p.clear(out)
+			return -1, ErrCanceled
+		}
+		switch r {
+		case '\r', '\n':
+			if len(p.matched) == 0 {
+				continue
+			}
+			p.clear(out)
+			return p.matched[p.cursor], nil
+		case keyCtrlC:
+			p.clear(out)
+			return -1, ErrCanceled
+		case keyCtrlP:
+			p.move(-1)
+		case keyCtrlN:
+			p.move(1)
+		case keyCtrlU:
+			p.query = p.query[:0]
+			p.filter()
+		case keyBackspace, keyDelete:
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case keyEscape:
+			// arrow keys are sent as ESC [ A or ESC O A
+			next, _, err := reader.ReadRune()
+			if err != nil || (next != '[' && next != 'O') {
+				continue
+			}
+			code, _, err := reader.ReadRune()
+			if err != nil {
+				continue
+			}
+			switch code {
+			case 'A':
+				p.move(-1)
+			case 'B':
+				p.move(1)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				p.query = append(p.query, r)
+				p.filter()
+			}
+		}
+	}
+}
+
+func (p *Picker) filter() {
+	p.matched = p.matched[:0]
+	for i, item := range p.Items {
+		if Match(string(p.query), item) {
+			p.matched = append(p.matched, i)
+		}
+	}
+	p.cursor = 0
+	p.offset = 0
+}
+
+func (p *Picker) move(delta int) {
+	if len(p.matched) == 0 {
+		return
+	}
+	p.cursor = min(max(p.cursor+delta, 0), len(p.matched)-1)
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.Height {
+		p.offset = p.cursor - p.Height + 1
+	}
+}
+
+func (p *Picker) draw(out io.Writer) {
+	p.clear(out)
+	lines := []string{}
+	if p.Header != "" {
+		lines = append(lines, "  "+p.Header)
+	}
+	for i := p.offset; i < min(p.offset+p.Height, len(p.matched)); i++ {
+		mark := "  "
+		if i == p.cursor {
+			mark = "> "
+		}
+		lines = append(lines, mark+p.Items[p.matched[i]])
+	}
+	lines = append(lines, fmt.Sprintf("%v (%v/%v) %v", p.Prompt, len(p.matched), len(p.Items), string(p.query)))
+	// raw mode terminal needs CR
+	fmt.Fprint(out, strings.Join(lines, "\r\n"))
+	p.lines = len(lines)
+}
+
+// clear erases the lines drawn last time.
+func (p *Picker) clear(out io.Writer) {
+	if p.lines == 0 {
+		return
+	}
+	fmt.Fprint(out, "\r")
+	if p.lines > 1 {
+		fmt.Fprintf(out, "\x1b[%vA", p.lines-1)
+	}
+	fmt.Fprint(out, "\x1b[J")
+	p.lines = 0
+}
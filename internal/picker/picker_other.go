@@ -0,0 +1,10 @@
+//go:build !windows
+
+package picker
+
+import "os"
+
+// enableVirtualTerminal does nothing because escape sequences are always enabled.
+func enableVirtualTerminal(out *os.File) func() {
+	return func() {}
+}
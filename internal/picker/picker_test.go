@@ -0,0 +1,55 @@
+package picker
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Match(t *testing.T) {
+	for _, q := range []string{"", "web", "WEB prod", "wb01", "i-0123 running"} {
+		if !Match(q, "web01-prod i-01234567890abcdef running") {
+			t.Errorf("%q must match", q)
+		}
+	}
+	for _, q := range []string{"bew", "web stopped", "web01x"} {
+		if Match(q, "web01-prod i-01234567890abcdef running") {
+			t.Errorf("%q must not match", q)
+		}
+	}
+}
+
+func Test_Picker_Run(t *testing.T) {
+	items := []string{"web01 running", "web02 stopped", "db01 running"}
+	tests := []struct {
+		input string
+		index int
+		err   error
+	}{
+		{"\r", 0, nil},
+		{"\x1b[B\x1b[B\r", 2, nil},
+		{"\x1b[B\x1b[A\r", 0, nil},
+		{"\x0e\x0e\x0e\x0e\r", 2, nil},
+		{"db\r", 2, nil},
+		{"web stop\r", 1, nil},
+		{"dbx\x7f\r", 2, nil},
+		{"xyz\r\x15\r", 0, nil},
+		{"web\x03", -1, ErrCanceled},
+		{"web", -1, ErrCanceled},
+	}
+	for _, tt := range tests {
+		out := &bytes.Buffer{}
+		p := &Picker{Prompt: "Choose", Header: "NAME STATE", Items: items}
+		index, err := p.Run(strings.NewReader(tt.input), out)
+		if index != tt.index || !errors.Is(err, tt.err) {
+			t.Errorf("Input %q must select %v (%v, %v)", tt.input, tt.index, index, err)
+		}
+	}
+
+	// no items
+	p := &Picker{Prompt: "Choose"}
+	if _, err := p.Run(strings.NewReader("\r"), &bytes.Buffer{}); err == nil {
+		t.Error("Failed to detect no items")
+	}
+}
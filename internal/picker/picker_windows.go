@@ -0,0 +1,20 @@
+//go:build windows
+
+package picker
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableVirtualTerminal enables escape sequences on the console, and returns the function to restore the console mode.
+func enableVirtualTerminal(out *os.File) func() {
+	handle := windows.Handle(out.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return func() {}
+	}
+	windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	return func() { windows.SetConsoleMode(handle, mode) }
+}
@@ -0,0 +1,137 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// the reconnected tunnel closed within this duration is not regarded as recovered.
+const stableDuration = 30 * time.Second
+
+// Reconnector reopens the tunnel on the same local port when it is closed unexpectedly.
+// NewTunnel is called for every attempt, so the new tunnel starts with fresh credentials.
+type Reconnector struct {
+	State
+	NewTunnel    func() Tunnel
+	MaxAttempts  int           // attempts for each disconnection
+	Interval     time.Duration // wait before the first attempt, doubled for every attempt
+	ReadyTimeout time.Duration
+	Logf         func(format string, a ...any)
+
+	mu      sync.Mutex
+	current Tunnel
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (r *Reconnector) Open(ctx context.Context) error {
+	t := r.NewTunnel()
+	err := t.Open(ctx)
+	if err != nil {
+		return err
+	}
+	r.current = t
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.wg.Add(1)
+	go r.watch()
+	return nil
+}
+
+func (r *Reconnector) WaitReady(ctx context.Context) error {
+	err := r.Current().WaitReady(ctx)
+	if err != nil {
+		return err
+	}
+	r.SetReady()
+	return nil
+}
+
+// Current returns the tunnel currently used.
+func (r *Reconnector) Current() Tunnel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+func (r *Reconnector) Close() error {
+	if r.cancel == nil {
+		return ErrClosed
+	}
+	r.cancel()
+	r.wg.Wait()
+	err := r.Current().Close()
+	r.SetDone(nil)
+	return err
+}
+
+func (r *Reconnector) String() string {
+	return fmt.Sprint(r.Current())
+}
+
+func (r *Reconnector) watch() {
+	defer r.wg.Done()
+	attempt := 0
+	interval := r.Interval
+	openedAt := time.Now()
+	for {
+		current := r.Current()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-current.Done():
+		}
+		cause := fmt.Errorf("%v is disconnected", current)
+		if s, ok := current.(interface{ Err() error }); ok && s.Err() != nil {
+			cause = s.Err()
+		}
+		r.logf("Tunnel is disconnected (%v)\n", cause)
+		// release the remote resources of the closed tunnel
+		current.Close()
+
+		// the tunnel closed soon after reconnecting is counted as the failed attempt.
+		if time.Since(openedAt) >= stableDuration {
+			attempt = 0
+			interval = r.Interval
+		}
+		for {
+			attempt++
+			if attempt > r.MaxAttempts {
+				err := fmt.Errorf("gave up reconnecting after %v attempts: %w", r.MaxAttempts, cause)
+				r.logf("Failed to reconnect (%v)\n", cause)
+				r.SetDone(err)
+				return
+			}
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			interval *= 2
+			r.logf("Reconnecting (attempt %v/%v)\n", attempt, r.MaxAttempts)
+			next := r.NewTunnel()
+			err := Start(r.ctx, next, r.ReadyTimeout)
+			if err == nil {
+				r.logf("Reconnected %v\n", next)
+				r.mu.Lock()
+				r.current = next
+				r.mu.Unlock()
+				openedAt = time.Now()
+				break
+			}
+			if r.ctx.Err() != nil {
+				return
+			}
+			r.logf("Attempt %v failed (%v)\n", attempt, err)
+			cause = err
+		}
+	}
+}
+
+func (r *Reconnector) logf(format string, a ...any) {
+	if r.Logf != nil {
+		r.Logf(format, a...)
+	}
+}
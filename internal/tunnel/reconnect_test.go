@@ -0,0 +1,129 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeFactory struct {
+	mu        sync.Mutex
+	tunnels   []*fakeTunnel
+	OpenError error // error of tunnels after the first one
+}
+
+func (f *fakeFactory) NewTunnel() Tunnel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTunnel{ReadyOnOpen: true}
+	if len(f.tunnels) > 0 {
+		t.OpenError = f.OpenError
+	}
+	f.tunnels = append(f.tunnels, t)
+	return t
+}
+
+func (f *fakeFactory) Count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tunnels)
+}
+
+func (f *fakeFactory) Get(i int) *fakeTunnel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tunnels[i]
+}
+
+func (f *fakeTunnel) String() string {
+	return "fake tunnel"
+}
+
+func newTestReconnector(factory *fakeFactory, logs chan<- string) *Reconnector {
+	return &Reconnector{
+		NewTunnel:    factory.NewTunnel,
+		MaxAttempts:  3,
+		Interval:     time.Millisecond,
+		ReadyTimeout: time.Second,
+		Logf: func(format string, a ...any) {
+			select {
+			case logs <- fmt.Sprintf(format, a...):
+			default:
+			}
+		},
+	}
+}
+
+func Test_Reconnector(t *testing.T) {
+	factory := &fakeFactory{}
+	logs := make(chan string, 100)
+	r := newTestReconnector(factory, logs)
+	if err := Start(context.Background(), r, time.Second); err != nil {
+		t.Fatalf("Failed to start tunnel (%v)", err)
+	}
+
+	// reconnect when the tunnel is disconnected
+	factory.Get(0).SetDone(errors.New("connection lost"))
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Current() == Tunnel(factory.Get(0)) {
+		if time.Now().After(deadline) {
+			t.Fatal("Failed to reconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !factory.Get(0).Closed.Load() {
+		t.Error("Disconnected tunnel must be closed")
+	}
+	if r.Current() != Tunnel(factory.Get(1)) {
+		t.Error("Current tunnel must be the reconnected one")
+	}
+	select {
+	case <-r.Done():
+		t.Error("Reconnector must not be closed")
+	default:
+	}
+	if log := <-logs; log != "Tunnel is disconnected (connection lost)\n" {
+		t.Errorf("Invalid log %q", log)
+	}
+	if log := <-logs; log != "Reconnecting (attempt 1/3)\n" {
+		t.Errorf("Invalid log %q", log)
+	}
+
+	// close current tunnel
+	if err := r.Close(); err != nil {
+		t.Errorf("Failed to close (%v)", err)
+	}
+	if !factory.Get(1).Closed.Load() {
+		t.Error("Current tunnel must be closed")
+	}
+	if r.Err() != nil {
+		t.Errorf("Reconnector must be closed normally (%v)", r.Err())
+	}
+}
+
+func Test_Reconnector_GiveUp(t *testing.T) {
+	factory := &fakeFactory{OpenError: errors.New("open error")}
+	logs := make(chan string, 100)
+	r := newTestReconnector(factory, logs)
+	if err := Start(context.Background(), r, time.Second); err != nil {
+		t.Fatalf("Failed to start tunnel (%v)", err)
+	}
+	defer r.Close()
+
+	// give up after max attempts
+	factory.Get(0).SetDone(nil)
+	select {
+	case <-r.Done():
+		if r.Err() == nil {
+			t.Error("Failed to report giving up")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reconnector must give up")
+	}
+	if factory.Count() != 4 {
+		t.Errorf("Invalid attempts %v", factory.Count()-1)
+	}
+}
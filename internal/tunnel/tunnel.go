@@ -0,0 +1,117 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tunnel forwards the local port to the remote port of EC2 instance.
+type Tunnel interface {
+	// Open starts listening the local port.
+	Open(ctx context.Context) error
+	// WaitReady waits until the tunnel is ready to relay connections.
+	WaitReady(ctx context.Context) error
+	// Done returns the channel closed when the tunnel is closed.
+	Done() <-chan struct{}
+	// Close closes the tunnel and releases its remote resources.
+	Close() error
+}
+
+var ErrClosed = errors.New("tunnel is closed")
+
+// State tracks readiness and closing of the tunnel.
+// Tunnel implementations embed it to share WaitReady and Done.
+type State struct {
+	initOnce  sync.Once
+	readyOnce sync.Once
+	doneOnce  sync.Once
+	ready     chan struct{}
+	done      chan struct{}
+	err       error
+}
+
+func (s *State) init() {
+	s.initOnce.Do(func() {
+		s.ready = make(chan struct{})
+		s.done = make(chan struct{})
+	})
+}
+
+// SetReady marks the tunnel ready.
+func (s *State) SetReady() {
+	s.init()
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+// SetDone marks the tunnel closed with the cause. nil cause means the tunnel is closed normally.
+func (s *State) SetDone(err error) {
+	s.init()
+	s.doneOnce.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+// Ready returns the channel closed when the tunnel gets ready.
+func (s *State) Ready() <-chan struct{} {
+	s.init()
+	return s.ready
+}
+
+func (s *State) Done() <-chan struct{} {
+	s.init()
+	return s.done
+}
+
+// Err returns the cause of closing after Done is closed.
+func (s *State) Err() error {
+	select {
+	case <-s.Done():
+		return s.err
+	default:
+		return nil
+	}
+}
+
+func (s *State) WaitReady(ctx context.Context) error {
+	// prefer readiness to closing and cancellation.
+	select {
+	case <-s.Ready():
+		return nil
+	default:
+	}
+	select {
+	case <-s.Ready():
+		return nil
+	case <-s.Done():
+		if s.err != nil {
+			return s.err
+		}
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Start opens the tunnel and waits until it gets ready within the timeout.
+// The tunnel is closed when it fails to get ready.
+func Start(ctx context.Context, t Tunnel, timeout time.Duration) error {
+	err := t.Open(ctx)
+	if err != nil {
+		return err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err = t.WaitReady(waitCtx)
+	if err != nil {
+		t.Close()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("tunnel is not ready within %v", timeout)
+		}
+		return err
+	}
+	return nil
+}
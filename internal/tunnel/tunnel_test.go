@@ -0,0 +1,103 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeTunnel struct {
+	State
+	OpenError   error
+	ReadyOnOpen bool
+	Closed      atomic.Bool
+}
+
+func (f *fakeTunnel) Open(ctx context.Context) error {
+	if f.OpenError != nil {
+		return f.OpenError
+	}
+	if f.ReadyOnOpen {
+		f.SetReady()
+	}
+	return nil
+}
+
+func (f *fakeTunnel) Close() error {
+	f.Closed.Store(true)
+	f.SetDone(nil)
+	return nil
+}
+
+func Test_Start(t *testing.T) {
+	// when tunnel gets ready
+	fake := &fakeTunnel{ReadyOnOpen: true}
+	if err := Start(context.Background(), fake, time.Second); err != nil {
+		t.Errorf("Failed to start tunnel (%v)", err)
+	}
+	if fake.Closed.Load() {
+		t.Error("Ready tunnel must not be closed")
+	}
+
+	// when tunnel fails to open
+	fake = &fakeTunnel{OpenError: errors.New("open error")}
+	if err := Start(context.Background(), fake, time.Second); err == nil || err.Error() != "open error" {
+		t.Errorf("Failed to detect open error (%v)", err)
+	}
+
+	// when tunnel does not get ready within the timeout
+	fake = &fakeTunnel{}
+	if err := Start(context.Background(), fake, 10*time.Millisecond); err == nil {
+		t.Error("Failed to detect timeout")
+	}
+	if !fake.Closed.Load() {
+		t.Error("Tunnel must be closed after timeout")
+	}
+}
+
+func Test_State(t *testing.T) {
+	// zero value is available
+	s := &State{}
+	if s.Err() != nil {
+		t.Error("Err must be nil before done")
+	}
+	s.SetReady()
+	s.SetReady()
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Error("Failed to wait ready")
+	}
+
+	// the first cause is kept
+	s = &State{}
+	cause := errors.New("connection lost")
+	s.SetDone(cause)
+	s.SetDone(nil)
+	select {
+	case <-s.Done():
+	default:
+		t.Error("Done channel must be closed")
+	}
+	if s.Err() != cause {
+		t.Errorf("Invalid cause %v", s.Err())
+	}
+	if err := s.WaitReady(context.Background()); err != cause {
+		t.Errorf("WaitReady must return the cause (%v)", err)
+	}
+
+	// closed normally
+	s = &State{}
+	s.SetDone(nil)
+	if err := s.WaitReady(context.Background()); err != ErrClosed {
+		t.Errorf("WaitReady must return ErrClosed (%v)", err)
+	}
+
+	// canceled
+	s = &State{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.WaitReady(ctx); err != context.Canceled {
+		t.Errorf("WaitReady must return context error (%v)", err)
+	}
+}
@@ -0,0 +1,56 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Poller calls the check function until it is done, with the interval doubled up to MaxInterval.
+type Poller struct {
+	Interval    time.Duration
+	MaxInterval time.Duration // Interval is not changed when zero
+	Timeout     time.Duration
+	Logf        func(format string, a ...any)
+}
+
+// Until calls check until it returns true. The description is used for progress and timeout messages.
+// The check function returns the status message shown as progress.
+func (p *Poller) Until(ctx context.Context, description string, check func(ctx context.Context) (bool, string, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	start := time.Now()
+	interval := p.Interval
+	for {
+		done, status, err := check(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		elapsed := time.Since(start).Round(time.Second)
+		if status != "" {
+			p.logf("Waiting for %v (%v, %v elapsed)\n", description, status, elapsed)
+		} else {
+			p.logf("Waiting for %v (%v elapsed)\n", description, elapsed)
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("timed out waiting for %v after %v", description, p.Timeout)
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		if p.MaxInterval > 0 {
+			interval = min(interval*2, p.MaxInterval)
+		}
+	}
+}
+
+func (p *Poller) logf(format string, a ...any) {
+	if p.Logf != nil {
+		p.Logf(format, a...)
+	}
+}
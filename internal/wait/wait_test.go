@@ -0,0 +1,53 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_Poller(t *testing.T) {
+	// done after retries
+	logs := []string{}
+	p := &Poller{Interval: time.Millisecond, MaxInterval: 4 * time.Millisecond, Timeout: time.Second, Logf: func(format string, a ...any) {
+		logs = append(logs, fmt.Sprintf(format, a...))
+	}}
+	count := 0
+	err := p.Until(context.Background(), "test", func(ctx context.Context) (bool, string, error) {
+		count++
+		return count == 3, "pending", nil
+	})
+	if err != nil || count != 3 || len(logs) != 2 {
+		t.Errorf("Failed to wait (%v, count=%v, logs=%v)", err, count, logs)
+	}
+
+	// error
+	errCheck := errors.New("check error")
+	err = p.Until(context.Background(), "test", func(ctx context.Context) (bool, string, error) {
+		return false, "", errCheck
+	})
+	if !errors.Is(err, errCheck) {
+		t.Errorf("Check error must be returned (%v)", err)
+	}
+
+	// timeout
+	p = &Poller{Interval: 10 * time.Millisecond, Timeout: 30 * time.Millisecond}
+	err = p.Until(context.Background(), "test", func(ctx context.Context) (bool, string, error) {
+		return false, "", nil
+	})
+	if err == nil || err.Error() != "timed out waiting for test after 30ms" {
+		t.Errorf("Failed to detect timeout (%v)", err)
+	}
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = p.Until(ctx, "test", func(ctx context.Context) (bool, string, error) {
+		return false, "", nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Canceled error must be returned (%v)", err)
+	}
+}